@@ -15,9 +15,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"github.com/gorilla/mux"
+	"github.com/maroda/hpschd/mesostic"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 )
@@ -26,6 +31,31 @@ import (
 type Submit struct {
 	Text        string
 	SpineString string
+	Algorithm   string // "50" (default), "100", or "acrostic"
+	Procedure   string // "lines" (default), "writing-through", or "diastic"
+	Fold        bool   // Spine letters match accented characters
+
+	MissLimit    int     // Skip a Spine letter after this many lines without it
+	MissFraction float64 // Skip a Spine letter after this fraction of the text without it
+
+	WestWidth int    // Most characters or words before the Spine letter
+	EastWidth int    // Most characters or words after the Spine letter
+	Wings     string // What the widths count, "chars" (default) or "words"
+	Bounded   bool   // Wings start and end between words
+	Adjacent  bool   // Keep only the words next to the Spine word
+
+	Stanzas bool // A blank line each time the Spine String completes
+	Cycles  int  // Stop after this many complete Spine Strings
+
+	Segment      string // How the text is split, "lines" (default), "sentences", "clauses", "width", or "paragraphs"
+	SegmentWidth int    // Most characters in a segment, for "width"
+
+	Unwrap      bool // Join hard-wrapped lines into paragraphs
+	Dehyphenate bool // Rejoin words hyphenated across lines
+	Normalize   bool // Remove BOMs and carriage returns, normalize to NFKC
+
+	Chance bool  // The I Ching chooses among the Spine letters in a line, line by line only
+	Seed   int64 // Seed for the I Ching, 0 picks one, the X-Hpschd-Seed header has the seed used
 }
 
 // homepage ::: Home
@@ -47,6 +77,8 @@ Decoupling the cron fetching the text from the display.
 type MesoPrint struct {
 	Title    string // Page Title
 	Mesostic string // The New Mesostic
+	Seed     string // The chance operation seed that selected the Mesostic, empty for the newest
+	Hexagram string // The hexagrams cast to select it, empty for the newest
 }
 
 func homepage(w http.ResponseWriter, r *http.Request) {
@@ -54,7 +86,16 @@ func homepage(w http.ResponseWriter, r *http.Request) {
 	defer hTimer.ObserveDuration()
 	_, _, fu := Envelope()
 
-	w.WriteHeader(http.StatusOK)
+	// a seed replays the chance operation of an earlier page
+	var seed int64
+	replay := r.URL.Query().Get("seed")
+	if replay != "" {
+		var err error
+		if seed, err = parseSeed(replay); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// struct for importing into the HTML template
 	var formatMeso MesoPrint
@@ -67,25 +108,36 @@ func homepage(w http.ResponseWriter, r *http.Request) {
 	// when the channel is empty, this function returns a special signal HPSCHD
 	// (does the channel stay empty if the read function isn't run? does it block the cronjob? will the cronjob pile up jobs if this happens?)
 	// this instructs the loading homepage to randomly select a previously created mesostic
-	var mesoFile string = nasaNewREAD()
+	var mesoFile string
+	if replay == "" {
+		mesoFile = nasaNewREAD()
+	}
 
 	switch mesoFile {
-	case "HPSCHD":
-		// The channel reader has returned the signal for "no more data".
-		// TODO: currently this isn't random, it needs to be
-		mesoDir := "store"               // An ephemeral 'datastore' of previously created mesostics.
-		iMesoFile := ichingMeso(mesoDir) // The i-ching-like engine for choosing a random mesostic.
+	case "HPSCHD", "":
+		// The channel reader has returned the signal for "no more data", or a seed was given.
+		if replay == "" {
+			seed = newSeed()
+		}
+		mesoDir := "store"                           // An ephemeral 'datastore' of previously created mesostics.
+		iMesoFile, cast := ichingMeso(mesoDir, seed) // The I Ching chooses a stored mesostic.
 		formatMeso.Title = iMesoFile
-		formatMeso.Mesostic = readMesoFile(&iMesoFile)
+		formatMeso.Mesostic = readStored(&iMesoFile).Text
+		formatMeso.Seed = strconv.FormatInt(seed, 10)
+		formatMeso.Hexagram = hexagrams(cast)
+		w.Header().Set("X-Hpschd-Seed", formatMeso.Seed)
+		w.Header().Set("X-Hpschd-Hexagram", formatMeso.Hexagram)
 
 		log.Info().
 			Str("fu", fu).
-			Str("filename", mesoFile).
+			Str("filename", iMesoFile).
+			Int64("seed", seed).
+			Str("hexagram", formatMeso.Hexagram).
 			Msg("Chance Operations Indicated")
 	default:
 		// A filename exists on the channel and has been returned.
 		formatMeso.Title = mesoFile
-		formatMeso.Mesostic = readMesoFile(&mesoFile)
+		formatMeso.Mesostic = readStored(&mesoFile).Text
 
 		log.Info().
 			Str("fu", fu).
@@ -94,6 +146,7 @@ func homepage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// display the new mesostic on the homepage
+	w.WriteHeader(http.StatusOK)
 	hometmpl := template.Must(template.ParseFiles("public/index.html"))
 	err := hometmpl.Execute(w, formatMeso)
 	if err != nil {
@@ -112,21 +165,54 @@ func homepage(w http.ResponseWriter, r *http.Request) {
 		Msg("")
 }
 
-// FSubmit ::: POST Method form submission.
+// FSubmit ::: POST Method form submission, the path {arg} is the Spine String.
+// The source is the 'text' field or a .txt file uploaded as 'file', see formSubmit().
+// The response is the plain text Mesostic, or an HTML page when asked for with the Accept header or 'format'.
+//
+//	curl -F file=@ulysses.txt localhost:9999/app/joyce
 func FSubmit(w http.ResponseWriter, r *http.Request) {
 	hTimer := prometheus.NewTimer(hpschdFsubTimer)
 	defer hTimer.ObserveDuration()
 
-	w.WriteHeader(http.StatusOK)
+	format := negotiate(r, mesoText, mesoHTML)
+	if name := strings.ToLower(r.URL.Query().Get("format")); name != "" {
+		switch formatNames[name] {
+		case mesoText, mesoHTML:
+			format = formatNames[name]
+		default:
+			writeError(w, &apiError{Status: http.StatusNotAcceptable, Field: "format", Msg: fmt.Sprintf("unsupported format %q, use text or html", name)})
+			return
+		}
+	}
 
 	// Take the given path as the Spine String.
-	args := mux.Vars(r)
-	spine := args["arg"]
-	fmt.Printf("spine = %s\n", spine)
+	subd, err := formSubmit(w, r, mux.Vars(r)["arg"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts, err := subd.validate(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType(format))
+	seedHeader(w, opts.Seed)
 
-	r.ParseForm()
-	for k, v := range r.Form {
-		fmt.Printf("%s = %s\n", k, v)
+	var res mesostic.Result
+	switch format {
+	case mesoText:
+		res, err = mesostic.Stream(r.Context(), w, strings.NewReader(subd.Text), subd.SpineString, opts)
+	default:
+		res, err = mesostic.Generate(r.Context(), strings.NewReader(subd.Text), subd.SpineString, opts)
+		if err == nil {
+			err = render(w, format, res)
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("mesostic failed")
+		return
 	}
 
 	log.Info().
@@ -138,34 +224,126 @@ func FSubmit(w http.ResponseWriter, r *http.Request) {
 		Str("proto", r.Proto).
 		Str("agent", r.Header.Get("User-Agent")).
 		Str("response", "200").
+		Str("format", format).
+		Int("lines", res.Source).
+		Int("matched", res.Matched).
+		Int64("seed", res.Seed).
 		Msg("New Form Submission")
 }
 
+// Options ::: Mesostic engine options from a submission.
+// The query (q) covers clients that can't change the body, the body wins.
+func (subd Submit) Options(q url.Values) (mesostic.Options, error) {
+	algorithm := subd.Algorithm
+	if algorithm == "" {
+		algorithm = q.Get("algorithm")
+	}
+	mode, err := mesostic.ParseAlgorithm(algorithm)
+	if err != nil {
+		return mesostic.Options{}, err
+	}
+
+	procedure := subd.Procedure
+	if procedure == "" {
+		procedure = q.Get("procedure")
+	}
+	proc, err := mesostic.ParseProcedure(procedure)
+	if err != nil {
+		return mesostic.Options{}, err
+	}
+
+	unit, err := mesostic.ParseWingUnit(subd.Wings)
+	if err != nil {
+		return mesostic.Options{}, err
+	}
+
+	segment := subd.Segment
+	if segment == "" {
+		segment = q.Get("segment")
+	}
+	seg, err := mesostic.ParseSegmentation(segment)
+	if err != nil {
+		return mesostic.Options{}, err
+	}
+
+	// a chance seed is picked here, so every response format and the log can report it
+	seed := subd.Seed
+	if subd.Chance && seed == 0 {
+		seed = newSeed()
+	}
+
+	return mesostic.Options{
+		Algorithm:      mode,
+		Procedure:      proc,
+		FoldDiacritics: subd.Fold,
+		MissLimit:      subd.MissLimit,
+		MissFraction:   subd.MissFraction,
+		WestWidth:      subd.WestWidth,
+		EastWidth:      subd.EastWidth,
+		WingUnit:       unit,
+		WordBoundaries: subd.Bounded,
+		AdjacentWords:  subd.Adjacent,
+		Stanzas:        subd.Stanzas,
+		MaxCycles:      subd.Cycles,
+		Segment:        seg,
+		SegmentWidth:   subd.SegmentWidth,
+		Unwrap:         subd.Unwrap,
+		Dehyphenate:    subd.Dehyphenate,
+		Normalize:      subd.Normalize,
+		Chance:         subd.Chance,
+		Seed:           seed,
+	}, nil
+}
+
 // JSubmit ::: POST Method JSON submission.
+// The response is a JSON document of the Mesostic by default, see mesoFormat() for the others.
+// A bad submission is answered with a 4xx status and a JSON error body, see validate().
 func JSubmit(w http.ResponseWriter, r *http.Request) {
 	hTimer := prometheus.NewTimer(hpschdJsubTimer)
 	defer hTimer.ObserveDuration()
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	var subd Submit
+	format, err := mesoFormat(r)
+	if err != nil {
+		writeError(w, &apiError{Status: http.StatusNotAcceptable, Field: "format", Msg: err.Error()})
+		return
+	}
 
 	// decode body into struct
-	if err := json.NewDecoder(r.Body).Decode(&subd); err != nil {
-		log.Fatal().Err(err).Msg("failed to decode body")
+	subd, err := decodeSubmit(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts, err := subd.validate(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 	source := subd.Text       // the multi-line source for the Mesostic
 	spine := subd.SpineString // the SpineString for the Mesostic
 
-	fileName := fileTmp(&spine, &source)
-	mcMeso := make(chan string)
-	go mesoMain(fileName, spine, mcMeso)
-
-	// receive the channel data and display result
-	showR := <-mcMeso
-	fmt.Println(showR)
-	fmt.Fprintf(w, "%s\n", showR)
+	w.Header().Set("Content-Type", contentType(format))
+	seedHeader(w, opts.Seed)
+
+	var res mesostic.Result
+	switch format {
+	case mesoText:
+		// the mesostic is written straight to the response, nothing is written if it fails
+		res, err = mesostic.Stream(r.Context(), w, strings.NewReader(source), spine, opts)
+		if err == nil {
+			fmt.Fprintln(w)
+		}
+	default:
+		res, err = mesostic.Generate(r.Context(), strings.NewReader(source), spine, opts)
+		if err == nil {
+			err = render(w, format, res)
+		}
+	}
+	if err != nil {
+		// the options are valid, so the request was cancelled or the response could not be written
+		log.Warn().Err(err).Msg("mesostic failed")
+		return
+	}
 
 	log.Info().
 		Str("host", r.Host).
@@ -176,10 +354,82 @@ func JSubmit(w http.ResponseWriter, r *http.Request) {
 		Str("proto", r.Proto).
 		Str("agent", r.Header.Get("User-Agent")).
 		Str("response", "200").
-		Str("tmp", fileName).
+		Str("format", format).
+		Int("lines", res.Source).
+		Int("matched", res.Matched).
+		Int("skipped", len(res.Skipped)).
+		Int64("seed", res.Seed).
 		Msg("New JSON")
 }
 
+// storeList ::: GET the names of the stored Mesostics, as JSON or plain text.
+func storeList(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	for _, entry := range dirents("store") {
+		names = append(names, entry.Name())
+	}
+
+	switch negotiate(r, mesoJSON, mesoText) {
+	case mesoText:
+		w.Header().Set("Content-Type", contentType(mesoText))
+		for _, name := range names {
+			fmt.Fprintln(w, name)
+		}
+	default:
+		w.Header().Set("Content-Type", mesoJSON)
+		if names == nil {
+			names = []string{}
+		}
+		json.NewEncoder(w).Encode(names)
+	}
+}
+
+// storeMeso ::: GET a stored Mesostic by name in any format, see mesoFormat().
+func storeMeso(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		http.Error(w, "bad mesostic name", http.StatusBadRequest)
+		return
+	}
+
+	format, err := mesoFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	mesoFile := filepath.Join("store", name)
+	if !extent(mesoFile) {
+		http.NotFound(w, r)
+		return
+	}
+	res := readStored(&mesoFile)
+
+	w.Header().Set("Content-Type", contentType(format))
+	seedHeader(w, res.Seed)
+	if err := render(w, format, res); err != nil {
+		log.Error().Err(err).Str("filename", mesoFile).Msg("cannot render mesostic")
+	}
+
+	log.Info().
+		Str("host", r.Host).
+		Str("ref", r.RemoteAddr).
+		Str("xref", r.Header.Get("X-Forwarded-For")).
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Str("proto", r.Proto).
+		Str("agent", r.Header.Get("User-Agent")).
+		Str("format", format).
+		Str("response", "200").
+		Msg("Stored Mesostic")
+}
+
+// composer ::: The page for composing Mesostics in a browser, it posts to /app.
+func composer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType(mesoHTML))
+	http.ServeFile(w, r, "public/composer.html")
+}
+
 // readiness checks are Counted but not logged
 func ping(w http.ResponseWriter, r *http.Request) {
 	hpschdPingCount.Add(1)
@@ -0,0 +1,376 @@
+/*
+
+	API Tests
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/maroda/hpschd/mesostic"
+)
+
+// TestTJSubmitConcurrent ::: Hundreds of parallel /app requests each get their own Mesostic.
+// Run with -race to catch any state shared between requests.
+func TestTJSubmitConcurrent(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target JSubmit() concurrency :::\n")
+
+	rt := mux.NewRouter()
+	rt.HandleFunc("/app", JSubmit).Methods(http.MethodPost)
+	ts := httptest.NewServer(rt)
+	defer ts.Close()
+
+	source, err := os.ReadFile("sources/lorenipsum-plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every combination of spine and algorithm has a known result built up front.
+	spines := []string{"craque", "lorem", "ipsum", "cage"}
+	algorithms := []string{"50", "100", "acrostic"}
+	want := make(map[string]string)
+	var subs []Submit
+	for _, spine := range spines {
+		for _, name := range algorithms {
+			mode, _ := mesostic.ParseAlgorithm(name)
+			res, err := mesostic.Generate(context.Background(), bytes.NewReader(source), spine, mesostic.Options{Algorithm: mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want[spine+name] = res.Text
+			subs = append(subs, Submit{Text: string(source), SpineString: spine, Algorithm: name})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 300; i++ {
+		sub := subs[i%len(subs)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			body, _ := json.Marshal(sub)
+			resp, err := http.Post(ts.URL+"/app", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+
+			var got mesostic.Result
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Error(err)
+				return
+			}
+			if got.Text != want[sub.SpineString+sub.Algorithm] {
+				t.Errorf("%s/%s mesostic differs:\n%s\nwant:\n%s", sub.SpineString, sub.Algorithm, got.Text, want[sub.SpineString+sub.Algorithm])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestTJSubmitFormats ::: /app answers with a JSON document, or plain text when asked.
+func TestTJSubmitFormats(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target JSubmit() formats :::\n")
+
+	body := `{"text": "the quick brown\nfox jumps over\nthe lazy dog\n", "spinestring": "cra"}`
+	plain := "      the quiCk b\nfox jumps oveR\n        the lAzy dog\n              \n\n"
+
+	// JSON by default
+	req := httptest.NewRequest(http.MethodPost, "/app", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	JSubmit(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != mesoJSON {
+		t.Errorf("Content-Type %q, want %q", ct, mesoJSON)
+	}
+	var doc struct {
+		SpineString string `json:"spinestring"`
+		Algorithm   string `json:"algorithm"`
+		Matched     int    `json:"matched"`
+		Missed      int    `json:"missed"`
+		Text        string `json:"text"`
+		Lines       []struct {
+			West  string `json:"west"`
+			Spine string `json:"spine"`
+			East  string `json:"east"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SpineString != "cra" || doc.Algorithm != "50" || doc.Matched != 3 || doc.Missed != 1 || len(doc.Lines) != 4 {
+		t.Errorf("JSON document %+v", doc)
+	}
+	if doc.Lines[0].West != "the qui" || doc.Lines[0].Spine != "C" || doc.Lines[0].East != "k b" {
+		t.Errorf("first line %+v", doc.Lines[0])
+	}
+	if doc.Text+"\n" != plain {
+		t.Errorf("JSON text %q", doc.Text)
+	}
+
+	// writing through matches more letters than there are lines, only the line without one is missed
+	req = httptest.NewRequest(http.MethodPost, "/app", strings.NewReader(`{"text": "the cat sat on a mat\nhmm", "spinestring": "cat", "procedure": "writing-through"}`))
+	rec = httptest.NewRecorder()
+	JSubmit(rec, req)
+	doc.Lines = nil
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Matched != 3 || doc.Missed != 1 || len(doc.Lines) != 3 {
+		t.Errorf("writing-through JSON document %+v", doc)
+	}
+
+	// a sentence longer than the engine reads at once is still answered
+	long, _ := json.Marshal(Submit{Text: strings.Repeat("the cat sat on a mat ", 70000), SpineString: "cat", Segment: "sentences"})
+	req = httptest.NewRequest(http.MethodPost, "/app", bytes.NewReader(long))
+	rec = httptest.NewRecorder()
+	JSubmit(rec, req)
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil || doc.Matched != 2 {
+		t.Errorf("long sentence: status %d, %d matched, %v", rec.Code, doc.Matched, err)
+	}
+
+	// plain text when asked for
+	req = httptest.NewRequest(http.MethodPost, "/app", strings.NewReader(body))
+	req.Header.Set("Accept", "text/plain")
+	rec = httptest.NewRecorder()
+	JSubmit(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != contentType(mesoText) {
+		t.Errorf("Content-Type %q, want %q", ct, contentType(mesoText))
+	}
+	if rec.Body.String() != plain {
+		t.Errorf("plain text %q, want %q", rec.Body.String(), plain)
+	}
+	if seed := rec.Header().Get("X-Hpschd-Seed"); seed != "" {
+		t.Errorf("seed %q without chance", seed)
+	}
+
+	// a chance Mesostic in plain text carries its seed, which replays it as JSON
+	chance := `{"text": "a cat, a rat, a bat\ncats and rats\ncrates at the coast\n", "spinestring": "cat", "chance": true`
+	req = httptest.NewRequest(http.MethodPost, "/app?format=text", strings.NewReader(chance+"}"))
+	rec = httptest.NewRecorder()
+	JSubmit(rec, req)
+	seed := rec.Header().Get("X-Hpschd-Seed")
+	if seed == "" {
+		t.Fatal("chance without a seed header")
+	}
+	req = httptest.NewRequest(http.MethodPost, "/app", strings.NewReader(chance+`, "seed": `+seed+"}"))
+	replay := httptest.NewRecorder()
+	JSubmit(replay, req)
+	var res mesostic.Result
+	if err := json.NewDecoder(replay.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(res.Seed) != seed || replay.Header().Get("X-Hpschd-Seed") != seed || res.Text+"\n" != rec.Body.String() {
+		t.Errorf("seed %s replayed as %d:\n%s\nwant:\n%s", seed, res.Seed, res.Text, rec.Body.String())
+	}
+}
+
+// TestTFSubmit ::: /app/{arg} builds a Mesostic from a form field or an uploaded .txt file.
+func TestTFSubmit(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target FSubmit() :::\n")
+
+	rt := mux.NewRouter()
+	rt.HandleFunc("/app/{arg}", FSubmit).Methods(http.MethodPost)
+
+	post := func(path, contentType string, body io.Reader, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		return rec
+	}
+	upload := func(field, filename, text string) (string, io.Reader) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile(field, filename)
+		io.WriteString(fw, text)
+		mw.WriteField("stanzas", "on")
+		mw.Close()
+		return mw.FormDataContentType(), &body
+	}
+
+	// a text field, answered in plain text
+	form := url.Values{"text": {"the quick brown\nfox jumps over\nthe lazy dog\n"}, "algorithm": {"50"}}
+	rec := post("/app/cra", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentType(mesoText) {
+		t.Errorf("text field: status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if want := "      the quiCk b\nfox jumps oveR\n        the lAzy dog\n              \n"; rec.Body.String() != want {
+		t.Errorf("text field %q, want %q", rec.Body.String(), want)
+	}
+
+	// an uploaded file, as curl -F file=@u2k.txt sends it, answered in HTML for a browser
+	source, err := os.ReadFile("sources/u2k.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := mesostic.Generate(context.Background(), bytes.NewReader(source), "joyce", mesostic.Options{Stanzas: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, body := upload("file", "u2k.txt", string(source))
+	rec = post("/app/joyce", ct, body, "")
+	if rec.Code != http.StatusOK || rec.Body.String() != want.Text {
+		t.Errorf("upload: status %d, %d bytes, want %d", rec.Code, rec.Body.Len(), len(want.Text))
+	}
+	ct, body = upload("file", "u2k.txt", string(source))
+	rec = post("/app/joyce", ct, body, "text/html")
+	if rec.Header().Get("Content-Type") != contentType(mesoHTML) || !strings.Contains(rec.Body.String(), `<span class="spine">J</span>`) {
+		t.Errorf("upload as html: %q", rec.Header().Get("Content-Type"))
+	}
+
+	// bad submissions
+	pdfType, pdf := upload("file", "ulysses.pdf", "%PDF-1.7")
+	tests := []struct {
+		path   string
+		ct     string
+		body   io.Reader
+		status int
+	}{
+		{"/app/cra", "application/x-www-form-urlencoded", strings.NewReader(""), http.StatusBadRequest},
+		{"/app/c4", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), http.StatusBadRequest},
+		{"/app/cra", "application/x-www-form-urlencoded", strings.NewReader(form.Encode() + "&stanzas=maybe"), http.StatusBadRequest},
+		{"/app/cra?format=svg", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), http.StatusNotAcceptable},
+		{"/app/joyce", pdfType, pdf, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		rec := post(tt.path, tt.ct, tt.body, "")
+		var ae apiError
+		if err := json.NewDecoder(rec.Body).Decode(&ae); err != nil || rec.Code != tt.status || ae.Status != tt.status {
+			t.Errorf("%s: status %d, want %d, %+v %v", tt.path, rec.Code, tt.status, ae, err)
+		}
+	}
+}
+
+// TestTstoreMeso ::: Stored Mesostics are listed and rendered in any format.
+func TestTstoreMeso(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target storeMeso() :::\n")
+
+	// Set up a store in a tmp working directory
+	TTdir := t.TempDir()
+	cwd, _ := os.Getwd()
+	if err := os.Chdir(TTdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	localDirs([]string{"store"})
+
+	res, err := mesostic.Generate(context.Background(), strings.NewReader("the quick brown\nfox jumps over\nthe lazy dog\n"), "cra", mesostic.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := json.Marshal(res)
+	spine, date, data := "cra", "1999-12-31", string(stored)
+	mesoFile, _ := apodNew(&spine, &date, &data)
+	name := strings.TrimPrefix(mesoFile, "store/")
+
+	rt := mux.NewRouter()
+	rt.HandleFunc("/store", storeList).Methods(http.MethodGet)
+	rt.HandleFunc("/store/{name}", storeMeso).Methods(http.MethodGet)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var names []string
+	if err := json.NewDecoder(get("/store", "").Body).Decode(&names); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != name {
+		t.Errorf("store list %v, want %s", names, name)
+	}
+
+	if rec := get("/store/"+name, "text/plain"); rec.Body.String() != res.Text {
+		t.Errorf("plain text %q, want %q", rec.Body.String(), res.Text)
+	}
+	if rec := get("/store/"+name+"?format=html", ""); !strings.Contains(rec.Body.String(), "<span class=\"spine\">C</span>") {
+		t.Errorf("html %q", rec.Body.String())
+	}
+	if rec := get("/store/"+name+"?format=md", ""); !strings.Contains(rec.Body.String(), "```\n"+res.Text+"```\n") {
+		t.Errorf("markdown %q, want %q", rec.Body.String(), res.Text)
+	}
+	var svg strings.Builder
+	if err := renderSVG(&svg, res); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("/store/"+name+"?format=svg", ""); rec.Body.String() != svg.String() {
+		t.Errorf("svg %q, want %q", rec.Body.String(), svg.String())
+	}
+	if rec := get("/store/"+name+"?format=pdf", ""); rec.Code != http.StatusNotAcceptable {
+		t.Errorf("format pdf: status %d", rec.Code)
+	}
+	if rec := get("/store/nothing.json", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing mesostic: status %d", rec.Code)
+	}
+}
+
+// TestThomepageSeed ::: A seed that is not a number is a bad request.
+func TestThomepageSeed(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target homepage() seed :::\n")
+
+	rec := httptest.NewRecorder()
+	homepage(rec, httptest.NewRequest(http.MethodGet, "/?seed=i-ching", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// TestTcomposer ::: The composer page is served, and every field it posts is one /app reads.
+func TestTcomposer(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target composer() :::\n")
+
+	rec := httptest.NewRecorder()
+	composer(rec, httptest.NewRequest(http.MethodGet, "/composer", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentType(mesoHTML) {
+		t.Fatalf("status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	page := rec.Body.String()
+	if !strings.Contains(page, `fetch("/app"`) {
+		t.Error("the composer does not post to /app")
+	}
+
+	fields := make(map[string]bool)
+	st := reflect.TypeFor[Submit]()
+	for i := range st.NumField() {
+		fields[strings.ToLower(st.Field(i).Name)] = true
+	}
+	names := regexp.MustCompile(`<(?:input|textarea|select)[^>]* name="(\w+)"`).FindAllStringSubmatch(page, -1)
+	if len(names) < 10 {
+		t.Errorf("%d fields on the composer", len(names))
+	}
+	for _, name := range names {
+		if !fields[name[1]] {
+			t.Errorf("the composer field %q is not part of a Submit", name[1])
+		}
+	}
+}
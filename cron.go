@@ -7,10 +7,15 @@
 package main
 
 import (
-	"os"
+	"context"
+	"encoding/json"
+	"math/rand/v2"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/maroda/hpschd/iching"
+	"github.com/maroda/hpschd/mesostic"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 )
@@ -19,8 +24,33 @@ import (
 // Buffered with capacity 1 to prevent blocking on initial startup fetch
 var nasaNewMESO = make(chan string, 1)
 
+// etlSeeds ::: The chance operation seeding every randomized ETL fetch, from HPSCHD_SEED when set.
+// The same HPSCHD_SEED replays the same sequence of APOD dates.
+var etlSeeds struct {
+	sync.Mutex
+	rnd *rand.Rand
+}
+
+// etlSeed ::: The seed for the next randomized ETL fetch.
+func etlSeed() int64 {
+	etlSeeds.Lock()
+	defer etlSeeds.Unlock()
+
+	if etlSeeds.rnd == nil {
+		seed, err := parseSeed(envVar("HPSCHD_SEED", ""))
+		if err != nil {
+			log.Error().Err(err).Msg("HPSCHD_SEED is not a number, using a new seed.")
+			seed = newSeed()
+		}
+		log.Info().Int64("seed", seed).Msg("ETL chance operations seeded")
+		etlSeeds.rnd = rand.New(rand.NewPCG(uint64(seed), iching.ChanceStream))
+	}
+	return etlSeeds.rnd.Int64()
+}
+
 // fetchTicker takes fetch frequency in seconds (ffs) and runs the ETL job
-func fetchTicker(ffs uint64) {
+// using the Mesostic engine options (opts).
+func fetchTicker(ffs uint64, opts mesostic.Options) {
 	// NASA official Astronomy Picture of the Day endpoint URL using NASA's demo API key
 	apiKey := envVar("NASA_API_KEY", "DEMO_KEY")
 	apodnow := "https://api.nasa.gov/planetary/apod?api_key=" + apiKey
@@ -33,7 +63,7 @@ func fetchTicker(ffs uint64) {
 	for {
 		select {
 		case <-ticker.C:
-			NASAetl(url)
+			NASAetl(url, opts)
 		}
 	}
 }
@@ -41,7 +71,8 @@ func fetchTicker(ffs uint64) {
 // NASAetl ::: Retrieve Astronomy Picture of the Day (APOD) metadata,
 // process it through the Mesostic engine, save it in a library of ephemeral copies,
 // pass the new data point (filename path) to a channel for use with displays.
-func NASAetl(url string) {
+// The Mesostic engine options (opts) are passed through to the engine.
+func NASAetl(url string, opts mesostic.Options) {
 	hTimer := prometheus.NewTimer(hpschdNASAetlTimer)
 	defer hTimer.ObserveDuration()
 
@@ -67,42 +98,46 @@ func NASAetl(url string) {
 	trcc := strings.NewReplacer(" ", "")
 	spn := trcc.Replace(spine)
 
-	// convert each phrase into a line by replacing commas and periods with newlines.
-	trnl := strings.NewReplacer(". ", "\n", ", ", "\n")
-	source = trnl.Replace(source)
+	// get a mesostic, each phrase is a line unless HPSCHD_SEGMENT says otherwise
+	res, err := mesostic.Generate(context.Background(), strings.NewReader(etlPhrases(source)), spn, opts)
+	if err != nil {
+		log.Error().Str("fu", fu).Err(err).Msg("Mesostic failed, waiting until next timed request.")
+		return
+	}
+	showR := res.Text
 
-	// get a mesostic
-	// this mimics the JSON API calls
-	// which will probably need to be revisited once this section is done
-	tmpFileName := fileTmp(&spn, &source)
-	mcMeso := make(chan string)
-	go mesoMain(tmpFileName, spn, mcMeso)
-	showR := <-mcMeso
+	// the store keeps the whole result, so every format can be rendered from it
+	stored, err := json.Marshal(res)
+	if err != nil {
+		log.Error().Str("fu", fu).Err(err).Msg("Mesostic cannot be stored.")
+		return
+	}
+	storedR := string(stored)
+
+	for _, sk := range res.Skipped {
+		log.Info().Str("fu", fu).Str("char", sk.Char).Int("line", sk.Line).Msg("Spine character skipped")
+	}
 
 	// create new Mesostic file
-	mesoFile, created := apodNew(&spine, &date, &showR)
+	mesoFile, created := apodNew(&spine, &date, &storedR)
 
 	// If the mesostic file already exists, no more action is needed.
 	// Trigger a new fetch for a new mesostic added to the store and quit.
 	// TODO: This check should go *before* creating the mesostic at all.
 	// 			e.g. construct the filename and check against dirents()
 	if !created {
-		go NASAetl(fetchRandURL())
+		seed := etlSeed()
+		go NASAetl(fetchRandURL(seed), opts)
 
 		log.Warn().
 			Str("fu", fu).
 			Str("code", "204").
+			Int64("seed", seed).
 			Msg("Local mesostic exists, randomized ETL triggered.")
 
 		return
 	}
 
-	// remove the tmp source file
-	var ferr = os.Remove(tmpFileName)
-	if ferr != nil {
-		log.Error()
-	}
-
 	// push filename of new Mesostic
 	nasaNewMESO <- mesoFile
 
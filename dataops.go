@@ -11,23 +11,62 @@
 package main
 
 import (
-	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 
+	"github.com/maroda/hpschd/iching"
+	"github.com/maroda/hpschd/mesostic"
 	"github.com/rs/zerolog/log"
 )
 
-// rndDate ::: Produce a random date in the format YYYY-MM-DD.
-// Note: salt parameter is unused with math/rand/v2 (auto-seeded), kept for API compatibility.
+// chance ::: The I Ching for a chance operation, the same seed always casts the same hexagrams.
+func chance(seed int64) *iching.Oracle {
+	return iching.New(seed)
+}
+
+// hexagrams ::: The King Wen numbers of the hexagrams (hs), for logs and pages.
+// A hexagram with changing lines is followed by its relating hexagram, e.g. "23>8".
+func hexagrams(hs []iching.Hexagram) string {
+	nums := make([]string, len(hs))
+	for i, h := range hs {
+		nums[i] = strconv.Itoa(h.Number)
+		if h.Relating != 0 {
+			nums[i] += ">" + strconv.Itoa(h.Relating)
+		}
+	}
+	return strings.Join(nums, " ")
+}
+
+// newSeed ::: A seed for a chance operation that was not given one.
+func newSeed() int64 {
+	return rand.Int64()
+}
+
+// parseSeed ::: Read a seed given as a string, an empty string is a new seed.
+func parseSeed(s string) (int64, error) {
+	if s == "" {
+		return newSeed(), nil
+	}
+	seed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("seed: %w", err)
+	}
+	return seed, nil
+}
+
+// rndDate ::: Produce a random date in the format YYYY-MM-DD, one hexagram for each of the year, month, and day.
+// The salt seeds the chance operation, the same salt always produces the same date.
 func rndDate(salt int64) string {
+	rnd := chance(salt)
+	var cast []iching.Hexagram
 
-	// rand ranges are [0,r)
+	// I Ching ranges are [0,r)
 	rMi := 20 // Millinium
 	rYr := 20 // Years
 	rMo := 12 // Months
@@ -37,20 +76,29 @@ func rndDate(salt int64) string {
 	Mi := fmt.Sprint(rMi)
 
 	// Yr can be zero
-	Yr := fmt.Sprintf("%02d", rand.IntN(rYr))
+	Yr := fmt.Sprintf("%02d", rnd.IntN(rYr))
+	cast = append(cast, rnd.Last()...)
 
 	// Don't actually use the last number but then add it back.
-	Mo := fmt.Sprintf("%02d", rand.IntN(rMo-1)+1)
+	Mo := fmt.Sprintf("%02d", rnd.IntN(rMo-1)+1)
+	cast = append(cast, rnd.Last()...)
 
 	// Good thing for a test:
 	// 	In rare cases this may be > 31,
 	// 	but the API should return a 404
 	// 	and that will trigger another random selection anyway.
-	Dy := fmt.Sprintf("%02d", rand.IntN(rDy)+1)
+	Dy := fmt.Sprintf("%02d", rnd.IntN(rDy)+1)
+	cast = append(cast, rnd.Last()...)
 
 	// Formatted YYYY-MM-DD date
 	newdate := Mi + Yr + "-" + Mo + "-" + Dy
 
+	log.Debug().
+		Str("date", newdate).
+		Int64("seed", salt).
+		Str("hexagrams", hexagrams(cast)).
+		Msg("APOD date cast")
+
 	return newdate
 }
 
@@ -63,8 +111,55 @@ func envVar(env, alt string) string {
 	return url
 }
 
-// ichingMeso ::: Uses chance operations to select an existing NASA APOD Mesostic.
-func ichingMeso(dir string) string {
+// etlOptions ::: Mesostic engine options for the NASA APOD ETL, from ENV VARs.
+//
+//	HPSCHD_ALGORITHM ::: 50 (default), 100, or acrostic
+//	HPSCHD_MISS_LIMIT ::: skip a Spine String character after this many lines without it, 0 (default) never skips
+//	HPSCHD_SEGMENT ::: lines, sentences, clauses, width, or paragraphs, unset keeps the phrases of etlPhrases()
+//	HPSCHD_SEGMENT_WIDTH ::: most characters in a segment for width, 40 (default)
+func etlOptions() (mesostic.Options, error) {
+	var opts mesostic.Options
+
+	mode, err := mesostic.ParseAlgorithm(envVar("HPSCHD_ALGORITHM", "50"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Algorithm = mode
+
+	limit, err := strconv.Atoi(envVar("HPSCHD_MISS_LIMIT", "0"))
+	if err != nil {
+		return opts, fmt.Errorf("HPSCHD_MISS_LIMIT: %w", err)
+	}
+	opts.MissLimit = limit
+
+	seg, err := mesostic.ParseSegmentation(envVar("HPSCHD_SEGMENT", ""))
+	if err != nil {
+		return opts, err
+	}
+	opts.Segment = seg
+
+	width, err := strconv.Atoi(envVar("HPSCHD_SEGMENT_WIDTH", "40"))
+	if err != nil {
+		return opts, fmt.Errorf("HPSCHD_SEGMENT_WIDTH: %w", err)
+	}
+	opts.SegmentWidth = width
+
+	return opts, nil
+}
+
+// etlPhrases ::: Each phrase of the APOD description (source) is a line, the periods and commas ending them become line returns.
+// The source is left as it is when HPSCHD_SEGMENT chooses a Segmentation.
+func etlPhrases(source string) string {
+	if envVar("HPSCHD_SEGMENT", "") != "" {
+		return source
+	}
+	trnl := strings.NewReplacer(". ", "\n", ", ", "\n")
+	return trnl.Replace(source)
+}
+
+// ichingMeso ::: Consults the I Ching to select an existing NASA APOD Mesostic, returning it with the hexagrams cast.
+// The same seed selects the same Mesostic for as long as the store holds the same files.
+func ichingMeso(dir string, seed int64) (string, []iching.Hexagram) {
 	var fileList []string
 	for _, entry := range dirents(dir) {
 		fullPath := filepath.Join(dir, entry.Name())
@@ -73,11 +168,12 @@ func ichingMeso(dir string) string {
 	}
 	if fileList == nil {
 		log.Error().Msg("ENOENT ::: Is the datastore available?")
-		return "ENOENT"
+		return "ENOENT", nil
 	}
 
-	randix := rand.IntN(len(fileList))
-	return fileList[randix]
+	oracle := chance(seed)
+	randix := oracle.IntN(len(fileList))
+	return fileList[randix], oracle.Last()
 }
 
 // dirents ::: read a directory and return its contents
@@ -127,6 +223,18 @@ func readMesoFile(f *string) string {
 	return string(mesoBuf)
 }
 
+// readStored ::: Open and read a stored Mesostic, written by NASAetl() as a JSON mesostic.Result.
+// Older stores hold only the plain text Mesostic, which is returned as the Result Text.
+func readStored(f *string) mesostic.Result {
+	var res mesostic.Result
+	data := readMesoFile(f)
+	if err := json.Unmarshal([]byte(data), &res); err != nil {
+		log.Debug().Err(err).Str("filename", *f).Msg("plain text mesostic")
+		return mesostic.Result{Text: data}
+	}
+	return res
+}
+
 // apodNEW ::: Check if a disk file exists in the Mesostic store or create a new one.
 // The return values are the filename and whether the function wrote a new file.
 func apodNew(sp *string, da *string, me *string) (string, bool) {
@@ -135,7 +243,7 @@ func apodNew(sp *string, da *string, me *string) (string, bool) {
 	mDir := "store"
 	tr := strings.NewReplacer(" ", "_")
 	spn := tr.Replace(*sp)
-	fP := fmt.Sprintf("%s/%s__%s", mDir, *da, spn)
+	fP := fmt.Sprintf("%s/%s__%s.json", mDir, *da, spn)
 
 	// NASA API returned a 404
 	if spn == "404" {
@@ -158,21 +266,6 @@ func apodNew(sp *string, da *string, me *string) (string, bool) {
 	return fP, true
 }
 
-// fileTmp ::: Take a source string and place it in a file name after the spinestring.
-// This only creates the file by a straight byte copy.
-// Calling functions are responsible for file deletion when finished.
-func fileTmp(sp *string, so *string) string {
-	fT := time.Now()
-	fS := fT.Unix()
-	fN := fmt.Sprintf("txrx/%s__%d", *sp, fS)
-	sB := []byte(*so)
-	err := os.WriteFile(fN, sB, 0644)
-	if err != nil {
-		log.Error()
-	}
-	return fN
-}
-
 // nasaNewREAD ::: Consume the current filename for the current NASA APOD Mesostic.
 // No new data returns the string 'HPSCHD'
 func nasaNewREAD() string {
@@ -189,12 +282,3 @@ func nasaNewREAD() string {
 		return "HPSCHD"
 	}
 }
-
-// SHA1 for consistent size keys
-func shakey(k string) string {
-	s := sha1.New()
-	s.Write([]byte(k))
-	bash := s.Sum(nil)
-	hash := fmt.Sprintf("%x", bash)
-	return hash
-}
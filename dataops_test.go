@@ -8,11 +8,14 @@ package main
 
 import (
 	"fmt"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/maroda/hpschd/mesostic"
 )
 
 // TestTlocalDirs ::: Create a local dirset, remove when done.
@@ -93,7 +96,7 @@ func TestTdirents(t *testing.T) {
 	}
 }
 
-// TestTichingMeso ::: When working, this will test the presence of a random filename. ???
+// TestTichingMeso ::: The same seed selects the same Mesostic from the store.
 func TestTichingMeso(t *testing.T) {
 	fmt.Printf("\n\t::: Test Target ichingMeso() :::\n")
 
@@ -104,33 +107,35 @@ func TestTichingMeso(t *testing.T) {
 	}
 	defer os.RemoveAll(TTdir)
 
-	TTfile, err := os.CreateTemp(TTdir, "")
-	if err != nil {
-		t.Error(err)
+	for i := range 10 {
+		TTfile, err := os.CreateTemp(TTdir, fmt.Sprint(i))
+		if err != nil {
+			t.Error(err)
+		}
+		TTfile.Close()
 	}
-	defer os.Remove(TTfile.Name())
 
 	// Call ichingMeso()
-}
-
-// TestTfileTmp ::: Special Mesostic TMP file creation.
-// Given a set of strings, match the created filename and verify its presence on disk,
-// perhaps match the content itself, then delete.
-func TestTfileTmp(t *testing.T) {
-	fmt.Printf("\n\t::: Test Target fileTmp() :::\n")
-
-	// Set up tmp
-	TTdir, err := os.MkdirTemp(".", "txrx")
-	if err != nil {
-		t.Error(err)
+	picks := make(map[string]bool)
+	for seed := range int64(20) {
+		pick, cast := ichingMeso(TTdir, seed)
+		if !extent(pick) {
+			t.Errorf("seed %d picked '%s', not in the store", seed, pick)
+		}
+		if len(cast) != 1 || cast[0].Number < 1 || cast[0].Number > 64 {
+			t.Errorf("seed %d cast %+v", seed, cast)
+		}
+		if again, _ := ichingMeso(TTdir, seed); again != pick {
+			t.Errorf("seed %d picked '%s', then '%s'", seed, pick, again)
+		}
+		picks[pick] = true
+	}
+	if len(picks) < 2 {
+		t.Errorf("20 seeds picked %d Mesostics", len(picks))
 	}
-	defer os.RemoveAll(TTdir)
 
-	spine := "cra"
-	source := "que"
-	fileName := fileTmp(&spine, &source)
-	if !strings.Contains(fileName, spine) {
-		t.Errorf("Local filename '%s' does not contain '%s'.\n", fileName, spine)
+	if pick, _ := ichingMeso(filepath.Join(TTdir, "none"), 1); pick != "ENOENT" {
+		t.Errorf("empty store picked '%s'", pick)
 	}
 }
 
@@ -183,16 +188,93 @@ func TestTenvVar(t *testing.T) {
 	t.Logf("set value received: %s", getvar)
 }
 
-// TestTrndDate ::: Test the creation of a random date
+// TestTrndDate ::: Test the creation of a random date, the same salt makes the same date.
 func TestTrndDate(t *testing.T) {
 	fmt.Printf("\n\t::: Test Target rndDate() :::\n")
 
 	salt := time.Now().Unix()
 	randomdate := rndDate(salt)
+	fmt.Println(randomdate)
+
+	if again := rndDate(salt); again != randomdate {
+		t.Errorf("salt %d made %s, then %s", salt, randomdate, again)
+	}
+
+	// the values fall within the ranges given, days past the end of a month are left to the API
+	date, err := time.Parse("2006-01-02", randomdate)
+	if err != nil && !strings.Contains(err.Error(), "day out of range") {
+		t.Fatal(err)
+	}
+	if err == nil && (date.Year() < 2000 || date.Year() > 2019) {
+		t.Errorf("%s is out of range", randomdate)
+	}
+}
 
-	// split the string by "-"
-	// now test by checking its values mathematically.
-	// see if the random values fall within the ranges given?
+// TestTetlOptions ::: Mesostic options for the ETL come from the environment.
+func TestTetlOptions(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target etlOptions() :::\n")
 
-	fmt.Println(randomdate)
+	t.Setenv("HPSCHD_ALGORITHM", "acrostic")
+	t.Setenv("HPSCHD_MISS_LIMIT", "3")
+	opts, err := etlOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Algorithm != mesostic.Acrostic || opts.MissLimit != 3 || opts.Segment != mesostic.Lines {
+		t.Errorf("options %+v", opts)
+	}
+
+	t.Setenv("HPSCHD_SEGMENT", "width")
+	t.Setenv("HPSCHD_SEGMENT_WIDTH", "30")
+	opts, err = etlOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Segment != mesostic.Width || opts.SegmentWidth != 30 {
+		t.Errorf("options %+v", opts)
+	}
+
+	t.Setenv("HPSCHD_SEGMENT", "words")
+	if _, err := etlOptions(); err == nil {
+		t.Error("HPSCHD_SEGMENT 'words' should fail")
+	}
+
+	t.Setenv("HPSCHD_SEGMENT", "")
+	t.Setenv("HPSCHD_MISS_LIMIT", "many")
+	if _, err := etlOptions(); err == nil {
+		t.Error("HPSCHD_MISS_LIMIT 'many' should fail")
+	}
+}
+
+// TestTetlPhrases ::: Without HPSCHD_SEGMENT the APOD description is split into phrases as it always was.
+func TestTetlPhrases(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target etlPhrases() :::\n")
+
+	source := "A comet, bright and new. It rises at dawn; look east!"
+
+	t.Setenv("HPSCHD_SEGMENT", "")
+	if got := etlPhrases(source); got != "A comet\nbright and new\nIt rises at dawn; look east!" {
+		t.Errorf("phrases %q", got)
+	}
+
+	t.Setenv("HPSCHD_SEGMENT", "clauses")
+	if got := etlPhrases(source); got != source {
+		t.Errorf("segmented source was changed: %q", got)
+	}
+}
+
+// TestTetlSeed ::: HPSCHD_SEED replays the same ETL seeds, from the chance operation stream.
+func TestTetlSeed(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target etlSeed() :::\n")
+
+	etlSeeds.rnd = nil
+	defer func() { etlSeeds.rnd = nil }()
+
+	t.Setenv("HPSCHD_SEED", "42")
+	want := rand.New(rand.NewPCG(42, 0x68707363686400))
+	for i := range 3 {
+		if got, w := etlSeed(), want.Int64(); got != w {
+			t.Errorf("seed %d is %d, want %d", i+1, got, w)
+		}
+	}
 }
@@ -94,9 +94,9 @@ func fetchSource(url string) (string, string, string) {
 }
 
 // fetchRandURL ::: Returns a constructed string using a random date for the NASA APOD API query.
-func fetchRandURL() string {
-	salt := time.Now().UnixNano()
-	date := rndDate(salt)
+// The date is chosen by rndDate() with the seed.
+func fetchRandURL(seed int64) string {
+	date := rndDate(seed)
 	apiKey := envVar("NASA_API_KEY", "DEMO_KEY")
 	url := "https://api.nasa.gov/planetary/apod?date=" + date + "&api_key=" + apiKey
 	return url
@@ -0,0 +1,156 @@
+/*
+
+	I Ching Chance Operations
+
+	Cage made his chance decisions by consulting the I Ching: three coins are tossed six times,
+	each toss building one line of a hexagram from the bottom up, and the hexagram's number (1-64)
+	answers the question. Lines of 6 or 9 are "old" and change into their opposites,
+	giving a second, relating hexagram.
+
+		oracle := iching.New(seed)
+		pick := oracle.IntN(len(choices))
+		hexagram := oracle.Last()[0]
+
+	The same seed always casts the same hexagrams, so every decision can be replayed.
+
+*/
+
+package iching
+
+import (
+	"math/rand/v2"
+)
+
+// Line ::: The sum of one toss of three coins, heads are 3 and tails are 2.
+type Line int
+
+// Lines of a hexagram
+const (
+	OldYin    Line = 6 // Broken, changing to unbroken
+	YoungYang Line = 7 // Unbroken
+	YoungYin  Line = 8 // Broken
+	OldYang   Line = 9 // Unbroken, changing to broken
+)
+
+// Yang ::: The line is unbroken.
+func (l Line) Yang() bool {
+	return l == YoungYang || l == OldYang
+}
+
+// Changing ::: The line is old and changes into its opposite.
+func (l Line) Changing() bool {
+	return l == OldYin || l == OldYang
+}
+
+// Hexagram ::: Six lines, from the bottom up, and what they mean.
+type Hexagram struct {
+	Number   int     `json:"number"`             // King Wen number, 1 to 64
+	Lines    [6]Line `json:"lines"`              // From the bottom line to the top
+	Changing []int   `json:"changing,omitempty"` // Positions of the changing lines, 1 to 6 from the bottom
+	Relating int     `json:"relating,omitempty"` // The hexagram once the changing lines change, 0 without any
+}
+
+// kingWen ::: Hexagram numbers by upper and lower trigram.
+// Trigrams are numbered by their lines, bottom line first, with unbroken lines as ones.
+var kingWen = [8][8]int{
+	// lower: Kun, Zhen, Kan, Dui, Gen, Li, Xun, Qian
+	{2, 24, 7, 19, 15, 36, 46, 11},   // upper Kun ☷ 000
+	{16, 51, 40, 54, 62, 55, 32, 34}, // upper Zhen ☳ 001
+	{8, 3, 29, 60, 39, 63, 48, 5},    // upper Kan ☵ 010
+	{45, 17, 47, 58, 31, 49, 28, 43}, // upper Dui ☱ 011
+	{23, 27, 4, 41, 52, 22, 18, 26},  // upper Gen ☶ 100
+	{35, 21, 64, 38, 56, 30, 50, 14}, // upper Li ☲ 101
+	{20, 42, 59, 61, 53, 37, 57, 9},  // upper Xun ☴ 110
+	{12, 25, 6, 10, 33, 13, 44, 1},   // upper Qian ☰ 111
+}
+
+// number ::: The King Wen number of six lines, true for unbroken.
+func number(yang [6]bool) int {
+	var lower, upper int
+	for i := range 3 {
+		if yang[i] {
+			lower |= 1 << i
+		}
+		if yang[i+3] {
+			upper |= 1 << i
+		}
+	}
+	return kingWen[upper][lower]
+}
+
+// FromLines ::: The Hexagram built from six lines, bottom first.
+func FromLines(lines [6]Line) Hexagram {
+	h := Hexagram{Lines: lines}
+
+	var primary, relating [6]bool
+	for i, l := range lines {
+		primary[i] = l.Yang()
+		relating[i] = l.Yang() != l.Changing()
+		if l.Changing() {
+			h.Changing = append(h.Changing, i+1)
+		}
+	}
+	h.Number = number(primary)
+	if h.Changing != nil {
+		h.Relating = number(relating)
+	}
+	return h
+}
+
+// ChanceStream ::: The PCG stream for every Oracle, and every other seeded chance operation, changing it changes every replay.
+const ChanceStream = 0x68707363686400 // "hpschd"
+
+// Oracle ::: Casts hexagrams with three coins, from a seeded random source.
+// An Oracle is not safe for concurrent use, each chance operation has its own.
+type Oracle struct {
+	rnd  *rand.Rand
+	last []Hexagram
+}
+
+// New ::: An Oracle that always casts the same hexagrams for the same seed.
+func New(seed int64) *Oracle {
+	return &Oracle{rnd: rand.New(rand.NewPCG(uint64(seed), ChanceStream))}
+}
+
+// toss ::: Three coins for one line.
+func (o *Oracle) toss() Line {
+	sum := 0
+	for range 3 {
+		sum += 2 + o.rnd.IntN(2)
+	}
+	return Line(sum)
+}
+
+// Cast ::: Toss the coins six times for a Hexagram.
+func (o *Oracle) Cast() Hexagram {
+	var lines [6]Line
+	for i := range lines {
+		lines[i] = o.toss()
+	}
+	return FromLines(lines)
+}
+
+// IntN ::: A choice among (n) things, in [0,n), by the hexagram numbers.
+// The 64 hexagrams are divided into (n) ranges as evenly as they go, as Cage did,
+// and more than 64 things take more hexagrams, each one a base 64 digit.
+// The hexagrams cast are kept for Last().
+func (o *Oracle) IntN(n int) int {
+	if n <= 0 {
+		panic("iching: invalid argument to IntN")
+	}
+
+	o.last = o.last[:0]
+	value, span := 0, 1
+	for span < n {
+		h := o.Cast()
+		o.last = append(o.last, h)
+		value = value*64 + h.Number - 1
+		span *= 64
+	}
+	return int(int64(value) * int64(n) / int64(span))
+}
+
+// Last ::: The hexagrams cast by the last IntN.
+func (o *Oracle) Last() []Hexagram {
+	return o.last
+}
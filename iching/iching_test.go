@@ -0,0 +1,108 @@
+/*
+
+	I Ching Tests
+
+*/
+
+package iching
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// TestTFromLines ::: Known hexagrams, their changing lines, and their relating hexagrams.
+func TestTFromLines(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target FromLines() :::\n")
+
+	tests := []struct {
+		lines    [6]Line
+		number   int
+		changing []int
+		relating int
+	}{
+		{[6]Line{7, 7, 7, 7, 7, 7}, 1, nil, 0},                     // Qian, the Creative
+		{[6]Line{8, 8, 8, 8, 8, 8}, 2, nil, 0},                     // Kun, the Receptive
+		{[6]Line{7, 8, 8, 8, 7, 8}, 3, nil, 0},                     // Zhun, thunder below water
+		{[6]Line{9, 9, 9, 9, 9, 9}, 1, []int{1, 2, 3, 4, 5, 6}, 2}, // every line changes
+		{[6]Line{7, 8, 7, 8, 7, 8}, 63, nil, 0},                    // Ji Ji, fire below water
+		{[6]Line{6, 7, 8, 7, 8, 7}, 64, []int{1}, 38},              // Wei Ji, the bottom line changes
+		{[6]Line{8, 8, 8, 8, 8, 9}, 23, []int{6}, 2},               // Bo, splitting apart
+	}
+	for _, tt := range tests {
+		h := FromLines(tt.lines)
+		if h.Number != tt.number || !slices.Equal(h.Changing, tt.changing) || h.Relating != tt.relating {
+			t.Errorf("%v: %+v, want %d %v %d", tt.lines, h, tt.number, tt.changing, tt.relating)
+		}
+	}
+
+	// the King Wen sequence holds every hexagram once
+	seen := make(map[int]bool)
+	for _, row := range kingWen {
+		for _, n := range row {
+			if n < 1 || n > 64 || seen[n] {
+				t.Errorf("hexagram %d out of range or repeated", n)
+			}
+			seen[n] = true
+		}
+	}
+}
+
+// TestTOracle ::: The same seed casts the same hexagrams, and the coins fall as coins do.
+func TestTOracle(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Oracle :::\n")
+
+	a, b := New(1952), New(1952)
+	for range 20 {
+		if ha, hb := a.Cast(), b.Cast(); ha.Number != hb.Number || ha.Lines != hb.Lines {
+			t.Fatalf("the same seed cast %+v and %+v", ha, hb)
+		}
+	}
+
+	// three coins make an old line one time in four, yang half the time
+	o := New(4)
+	lines := make(map[Line]int)
+	for range 10000 {
+		for _, l := range o.Cast().Lines {
+			lines[l]++
+		}
+	}
+	fmt.Println(lines)
+	for l, want := range map[Line]int{OldYin: 7500, YoungYang: 22500, YoungYin: 22500, OldYang: 7500} {
+		if n := lines[l]; n < want*9/10 || n > want*11/10 {
+			t.Errorf("line %d cast %d times, want about %d", l, n, want)
+		}
+	}
+}
+
+// TestTIntN ::: Choices stay in range, every one can be chosen, and more than 64 take more hexagrams.
+func TestTIntN(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Oracle.IntN() :::\n")
+
+	o := New(33)
+	for _, n := range []int{1, 2, 3, 12, 64, 65, 1000} {
+		seen := make(map[int]bool)
+		for range 20000 {
+			pick := o.IntN(n)
+			if pick < 0 || pick >= n {
+				t.Fatalf("IntN(%d) = %d", n, pick)
+			}
+			seen[pick] = true
+		}
+		if len(seen) != n {
+			t.Errorf("IntN(%d) chose %d of them", n, len(seen))
+		}
+
+		casts := 1
+		if n > 64 {
+			casts = 2
+		}
+		if n == 1 {
+			casts = 0
+		}
+		if len(o.Last()) != casts {
+			t.Errorf("IntN(%d) cast %d hexagrams, want %d", n, len(o.Last()), casts)
+		}
+	}
+}
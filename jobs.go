@@ -0,0 +1,340 @@
+/*
+
+	Mesostic Jobs
+
+	A whole novel takes a while, so it can be submitted as a job instead of waiting on /app.
+	Jobs wait in a bounded queue for a fixed pool of workers, report how many source lines
+	have been read, and can be cancelled. Finished jobs are kept for jobTTL.
+
+		POST /jobs ::: submit, the same body as /app or a form with a .txt upload
+		GET /jobs/{id} ::: status and progress
+		GET /jobs/{id}/result ::: the Mesostic, in any format
+		DELETE /jobs/{id} ::: cancel
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/maroda/hpschd/mesostic"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// jobTTL ::: How long a finished job is kept.
+const jobTTL = time.Hour
+
+// jobState ::: Where a job is in its life.
+type jobState string
+
+// Job states, a job ends done, failed, or cancelled.
+const (
+	jobQueued    jobState = "queued"
+	jobRunning   jobState = "running"
+	jobDone      jobState = "done"
+	jobFailed    jobState = "failed"
+	jobCancelled jobState = "cancelled"
+)
+
+// job ::: One Mesostic built in the background.
+type job struct {
+	id     string
+	total  int64        // source lines
+	lines  atomic.Int64 // source lines read so far
+	cancel context.CancelFunc
+	ctx    context.Context
+
+	mu       sync.Mutex
+	state    jobState
+	sub      Submit // the text is dropped once the job is finished
+	opts     mesostic.Options
+	res      mesostic.Result
+	err      *apiError
+	created  time.Time
+	started  time.Time
+	finished time.Time
+}
+
+// jobStatus ::: The JSON body describing a job.
+type jobStatus struct {
+	ID       string    `json:"id"`
+	Status   jobState  `json:"status"`
+	Lines    int64     `json:"lines"` // Source lines read so far
+	Total    int64     `json:"total"` // Source lines in all
+	Error    *apiError `json:"error,omitempty"`
+	Created  time.Time `json:"created"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+}
+
+// status ::: The job as it is now.
+func (j *job) status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return jobStatus{
+		ID:       j.id,
+		Status:   j.state,
+		Lines:    j.lines.Load(),
+		Total:    j.total,
+		Error:    j.err,
+		Created:  j.created,
+		Started:  j.started,
+		Finished: j.finished,
+	}
+}
+
+// ended ::: The job is done, failed, or cancelled.
+func (j *job) ended() bool {
+	return j.state == jobDone || j.state == jobFailed || j.state == jobCancelled
+}
+
+// jobQueue ::: The jobs, and the queue feeding the workers.
+type jobQueue struct {
+	mu    sync.Mutex
+	jobs  map[string]*job
+	queue chan *job
+}
+
+// newJobQueue ::: A queue holding at most (depth) jobs waiting for a worker.
+func newJobQueue(depth int) *jobQueue {
+	return &jobQueue{
+		jobs:  make(map[string]*job),
+		queue: make(chan *job, depth),
+	}
+}
+
+// start ::: Start (workers) workers, each runs one job at a time.
+func (q *jobQueue) start(workers int) {
+	for range workers {
+		go func() {
+			for j := range q.queue {
+				q.run(j)
+			}
+		}()
+	}
+}
+
+// run ::: Build the Mesostic for a job, unless it was cancelled while it waited.
+func (q *jobQueue) run(j *job) {
+	j.mu.Lock()
+	if j.state != jobQueued {
+		j.mu.Unlock()
+		return
+	}
+	j.state, j.started = jobRunning, time.Now()
+	sub, opts := j.sub, j.opts
+	j.mu.Unlock()
+
+	hTimer := prometheus.NewTimer(hpschdJobTimer)
+	src := &lineCounter{r: strings.NewReader(sub.Text), size: int64(len(sub.Text)), lines: &j.lines}
+	res, err := mesostic.Generate(j.ctx, src, sub.SpineString, opts)
+	hTimer.ObserveDuration()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = time.Now()
+	j.sub.Text = ""
+	switch {
+	case err == nil:
+		j.state, j.res = jobDone, res
+		j.lines.Store(j.total)
+	case j.ctx.Err() != nil:
+		j.state = jobCancelled
+	default:
+		j.state = jobFailed
+		j.err = &apiError{Status: http.StatusInternalServerError, Msg: err.Error()}
+	}
+	j.cancel()
+
+	log.Info().
+		Str("job", j.id).
+		Str("status", string(j.state)).
+		Int64("lines", j.lines.Load()).
+		Dur("runtime", j.finished.Sub(j.started)).
+		Msg("Job finished")
+}
+
+// get ::: The job for the request's {id}, or a 404 has been written.
+func (q *jobQueue) get(w http.ResponseWriter, r *http.Request) *job {
+	q.mu.Lock()
+	j := q.jobs[mux.Vars(r)["id"]]
+	q.mu.Unlock()
+
+	if j == nil {
+		writeError(w, &apiError{Status: http.StatusNotFound, Field: "id", Msg: "no such job"})
+	}
+	return j
+}
+
+// prune ::: Forget the jobs finished more than jobTTL ago.
+func (q *jobQueue) prune() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for id, j := range q.jobs {
+		j.mu.Lock()
+		if j.ended() && time.Since(j.finished) > jobTTL {
+			delete(q.jobs, id)
+		}
+		j.mu.Unlock()
+	}
+}
+
+// submit ::: POST Method job submission, the body of /app or a form like /app/{arg} with a 'spinestring' field.
+// The response is 202 with the job status, and its Location.
+func (q *jobQueue) submit(w http.ResponseWriter, r *http.Request) {
+	q.prune()
+
+	var subd Submit
+	var err error
+	switch ct := r.Header.Get("Content-Type"); {
+	case strings.HasPrefix(ct, "multipart/form-data"), strings.HasPrefix(ct, "application/x-www-form-urlencoded"):
+		subd, err = formSubmit(w, r, "")
+		subd.SpineString = r.Form.Get("spinestring")
+	default:
+		subd, err = decodeSubmit(w, r)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts, err := subd.validate(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	j := &job{
+		id:      rand.Text(),
+		total:   int64(strings.Count(subd.Text, "\n")),
+		state:   jobQueued,
+		sub:     subd,
+		opts:    opts,
+		created: time.Now(),
+	}
+	if !strings.HasSuffix(subd.Text, "\n") {
+		j.total++ // the last line has no line return
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	select {
+	case q.queue <- j:
+	default:
+		j.cancel()
+		w.Header().Set("Retry-After", "60")
+		writeError(w, &apiError{Status: http.StatusServiceUnavailable, Msg: "the job queue is full"})
+		return
+	}
+	q.mu.Lock()
+	q.jobs[j.id] = j
+	q.mu.Unlock()
+
+	log.Info().
+		Str("host", r.Host).
+		Str("ref", r.RemoteAddr).
+		Str("xref", r.Header.Get("X-Forwarded-For")).
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Str("job", j.id).
+		Int64("total", j.total).
+		Msg("Job queued")
+
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeStatus(w, http.StatusAccepted, j)
+}
+
+// status ::: GET Method job status and progress.
+func (q *jobQueue) status(w http.ResponseWriter, r *http.Request) {
+	if j := q.get(w, r); j != nil {
+		writeStatus(w, http.StatusOK, j)
+	}
+}
+
+// result ::: GET Method job result, in any format, see mesoFormat().
+// A job that is not done is a 409 with its status as the error.
+func (q *jobQueue) result(w http.ResponseWriter, r *http.Request) {
+	j := q.get(w, r)
+	if j == nil {
+		return
+	}
+
+	format, err := mesoFormat(r)
+	if err != nil {
+		writeError(w, &apiError{Status: http.StatusNotAcceptable, Field: "format", Msg: err.Error()})
+		return
+	}
+
+	j.mu.Lock()
+	state, res := j.state, j.res
+	j.mu.Unlock()
+	if state != jobDone {
+		writeError(w, &apiError{Status: http.StatusConflict, Field: "status", Msg: "the job is " + string(state)})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType(format))
+	seedHeader(w, res.Seed)
+	if err := render(w, format, res); err != nil {
+		log.Warn().Err(err).Str("job", j.id).Msg("cannot render job result")
+	}
+}
+
+// cancel ::: DELETE Method job cancellation, a finished job is left as it is.
+func (q *jobQueue) cancel(w http.ResponseWriter, r *http.Request) {
+	j := q.get(w, r)
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	if j.state == jobQueued {
+		// the worker skips it
+		j.state, j.finished = jobCancelled, time.Now()
+		j.sub.Text = ""
+	}
+	j.mu.Unlock()
+	j.cancel() // a running job stops at its next line
+
+	log.Info().Str("job", j.id).Msg("Job cancelled")
+	writeStatus(w, http.StatusOK, j)
+}
+
+// writeStatus ::: Write the job status as JSON with (code).
+func writeStatus(w http.ResponseWriter, code int, j *job) {
+	w.Header().Set("Content-Type", mesoJSON)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(j.status())
+}
+
+// lineCounter ::: Counts the source lines read from (r) into (lines), for the job progress.
+// The engine reads ahead a little, so the count runs a buffer ahead of the Mesostic.
+type lineCounter struct {
+	r     io.Reader
+	size  int64
+	lines *atomic.Int64
+}
+
+// Read ::: Read from the source, counting line returns.
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.lines.Add(int64(bytes.Count(p[:n], []byte("\n"))))
+	return n, err
+}
+
+// Size ::: The source size, for the miss tolerance.
+func (c *lineCounter) Size() int64 {
+	return c.size
+}
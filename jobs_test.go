@@ -0,0 +1,196 @@
+/*
+
+	Mesostic Jobs Tests
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/maroda/hpschd/mesostic"
+)
+
+// jobServer ::: A test server for the job routes of (q).
+func jobServer(q *jobQueue) *httptest.Server {
+	rt := mux.NewRouter()
+	rt.HandleFunc("/jobs", q.submit).Methods(http.MethodPost)
+	rt.HandleFunc("/jobs/{id}", q.status).Methods(http.MethodGet)
+	rt.HandleFunc("/jobs/{id}", q.cancel).Methods(http.MethodDelete)
+	rt.HandleFunc("/jobs/{id}/result", q.result).Methods(http.MethodGet)
+	return httptest.NewServer(rt)
+}
+
+// jobCall ::: Make a request, decoding a JSON body into (v) when it is not nil.
+func jobCall(t *testing.T, method, url string, body io.Reader, v any) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp
+}
+
+// jobSubmit ::: POST a job, returning its status, which is empty if it was turned away.
+func jobSubmit(t *testing.T, ts *httptest.Server, sub Submit) (*http.Response, jobStatus) {
+	t.Helper()
+	body, _ := json.Marshal(sub)
+	resp, err := http.Post(ts.URL+"/jobs", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var st jobStatus
+	if resp.StatusCode == http.StatusAccepted {
+		if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, st
+}
+
+// jobWait ::: Poll a job until it has ended.
+func jobWait(t *testing.T, ts *httptest.Server, id string) jobStatus {
+	t.Helper()
+	deadline := time.Now().Add(60 * time.Second)
+	for time.Now().Before(deadline) {
+		var st jobStatus
+		jobCall(t, http.MethodGet, ts.URL+"/jobs/"+id, nil, &st)
+		if st.Status != jobQueued && st.Status != jobRunning {
+			return st
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s never ended", id)
+	return jobStatus{}
+}
+
+// TestTjobs ::: A job is queued, runs to the end, and its result is the Mesostic from /app.
+func TestTjobs(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target jobQueue.submit() status() result() :::\n")
+
+	q := newJobQueue(4)
+	q.start(2)
+	ts := jobServer(q)
+	defer ts.Close()
+
+	source, err := os.ReadFile("sources/lorenipsum-plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := mesostic.Generate(context.Background(), bytes.NewReader(source), "lorem", mesostic.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, st := jobSubmit(t, ts, Submit{Text: string(source), SpineString: "lorem"})
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Location") != "/jobs/"+st.ID {
+		t.Fatalf("submit %d at %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if lines := int64(bytes.Count(source, []byte("\n"))); st.Total != lines && st.Total != lines+1 {
+		t.Errorf("total %d lines, the source has %d", st.Total, lines)
+	}
+
+	st = jobWait(t, ts, st.ID)
+	if st.Status != jobDone || st.Lines != st.Total || st.Finished.IsZero() {
+		t.Fatalf("finished job %+v", st)
+	}
+
+	var res mesostic.Result
+	jobCall(t, http.MethodGet, ts.URL+"/jobs/"+st.ID+"/result", nil, &res)
+	if res.Text != want.Text {
+		t.Errorf("job result:\n%s\nwant:\n%s", res.Text, want.Text)
+	}
+	resp = jobCall(t, http.MethodGet, ts.URL+"/jobs/"+st.ID+"/result?format=text", nil, nil)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), mesoText) {
+		t.Errorf("text result is %q", resp.Header.Get("Content-Type"))
+	}
+
+	// a finished job is left as it is
+	jobCall(t, http.MethodDelete, ts.URL+"/jobs/"+st.ID, nil, &st)
+	if st.Status != jobDone {
+		t.Errorf("cancelled a finished job: %+v", st)
+	}
+
+	// errors
+	var ae apiError
+	if resp := jobCall(t, http.MethodGet, ts.URL+"/jobs/nosuchjob", nil, &ae); resp.StatusCode != http.StatusNotFound || ae.Field != "id" {
+		t.Errorf("unknown job %d %+v", resp.StatusCode, ae)
+	}
+	if resp, _ := jobSubmit(t, ts, Submit{Text: "text", SpineString: "c-a"}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad spine %d", resp.StatusCode)
+	}
+}
+
+// TestTjobsCancel ::: Queued and running jobs are cancelled, a full queue turns jobs away.
+func TestTjobsCancel(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target jobQueue.cancel() :::\n")
+
+	q := newJobQueue(2)
+	ts := jobServer(q)
+	defer ts.Close()
+
+	source, err := os.ReadFile("sources/u2k.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := Submit{Text: strings.Repeat(string(source), 20), SpineString: "joyce"}
+
+	// no workers yet, so the jobs wait
+	_, running := jobSubmit(t, ts, sub)
+	_, queued := jobSubmit(t, ts, sub)
+	resp, _ := jobSubmit(t, ts, sub)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("full queue %d", resp.StatusCode)
+	}
+
+	var st jobStatus
+	jobCall(t, http.MethodDelete, ts.URL+"/jobs/"+queued.ID, nil, &st)
+	if st.Status != jobCancelled || st.Lines != 0 {
+		t.Errorf("cancelled queued job %+v", st)
+	}
+	var ae apiError
+	if resp := jobCall(t, http.MethodGet, ts.URL+"/jobs/"+queued.ID+"/result", nil, &ae); resp.StatusCode != http.StatusConflict {
+		t.Errorf("result of a cancelled job %d %+v", resp.StatusCode, ae)
+	}
+
+	// the worker takes the first job, and skips the cancelled one
+	q.start(1)
+	for st.Status != jobRunning {
+		jobCall(t, http.MethodGet, ts.URL+"/jobs/"+running.ID, nil, &st)
+		if st.Status != jobQueued && st.Status != jobRunning {
+			t.Fatalf("job ended before it was cancelled: %+v", st)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	jobCall(t, http.MethodDelete, ts.URL+"/jobs/"+running.ID, nil, nil)
+	if st = jobWait(t, ts, running.ID); st.Status != jobCancelled || st.Lines >= st.Total {
+		t.Errorf("cancelled running job %+v", st)
+	}
+	if st = jobWait(t, ts, queued.ID); st.Status != jobCancelled || !st.Started.IsZero() {
+		t.Errorf("cancelled queued job was run %+v", st)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+
+	Live Composer
+
+	A WebSocket session that answers every edit with the Mesostic as it now stands.
+	The client sends edits as JSON, any fields of the submission to /app, and each one is merged
+	into the session's submission, so typing in the text only has to send the text.
+	Edits are debounced, and a generation still running when a newer edit arrives is cancelled,
+	so only the latest submission is ever answered.
+
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/maroda/hpschd/mesostic"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// liveDebounce ::: How long the edits have to pause before the Mesostic is generated.
+const liveDebounce = 150 * time.Millisecond
+
+// liveUpgrader ::: Upgrades /live requests, only from pages on the same host.
+var liveUpgrader = websocket.Upgrader{ReadBufferSize: 4096, WriteBufferSize: 4096}
+
+// liveEdit ::: A change from the client, numbered by the client (Rev) to match the replies to it.
+type liveEdit struct {
+	Rev int `json:"rev"`
+	Submit
+
+	err error // the edit could not be read
+}
+
+// liveReply ::: The Mesostic for the edit (Rev), or why there is none.
+type liveReply struct {
+	Rev    int              `json:"rev"`              // The client's number for the edit answered
+	Same   int              `json:"same"`             // Leading lines unchanged since the last Mesostic, the client can redraw only the rest
+	Result *mesostic.Result `json:"result,omitempty"` // The Mesostic
+	Error  *apiError        `json:"error,omitempty"`  // Why there is no Mesostic
+}
+
+// liveResult ::: A finished generation (gen) for the session loop.
+type liveResult struct {
+	gen   int
+	reply liveReply
+	res   mesostic.Result
+}
+
+// live ::: GET Method WebSocket for composing a Mesostic as it is written.
+func live(w http.ResponseWriter, r *http.Request) {
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warn().Err(err).Msg("live upgrade failed") // the upgrader has replied
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxBody)
+
+	log.Info().
+		Str("host", r.Host).
+		Str("ref", r.RemoteAddr).
+		Str("xref", r.Header.Get("X-Forwarded-For")).
+		Str("agent", r.Header.Get("User-Agent")).
+		Msg("Live session started")
+
+	// the reader stops once the session has, closing the connection unblocks it
+	ctx, stop := context.WithCancel(r.Context())
+	defer stop()
+
+	edits := make(chan liveEdit)
+	go readEdits(ctx, conn, edits)
+
+	var (
+		latest  liveEdit                        // the newest edit, merged
+		gen     int                             // the newest generation, an edit moves it on so earlier results are dropped
+		cancel  = context.CancelFunc(func() {}) // cancels the newest generation
+		results = make(chan liveResult)         // finished generations
+		prev    mesostic.Result                 // the last Mesostic sent
+	)
+	debounce := time.NewTimer(liveDebounce)
+	debounce.Stop()
+	defer func() { cancel() }()
+
+	for {
+		select {
+		case edit, ok := <-edits:
+			if !ok {
+				log.Info().Str("ref", r.RemoteAddr).Msg("Live session ended")
+				return
+			}
+			if edit.err != nil {
+				if err := conn.WriteJSON(liveReply{Rev: edit.Rev, Error: badRequest("", "edit is not a JSON submission: %s", edit.err)}); err != nil {
+					return
+				}
+				continue
+			}
+
+			// whatever is running is for an older edit, even a result it is about to send
+			cancel()
+			gen++
+			latest = edit
+			debounce.Reset(liveDebounce)
+
+		case <-debounce.C:
+			gen++
+			cancel = liveStart(ctx, gen, latest, r.URL.Query(), results)
+
+		case done := <-results:
+			if done.gen != gen {
+				continue // for an older edit, finished just as it was cancelled
+			}
+			if done.reply.Result != nil {
+				done.reply.Same = sameLines(prev, done.res)
+				prev = done.res
+			}
+			if err := conn.WriteJSON(done.reply); err != nil {
+				log.Warn().Err(err).Msg("live reply failed")
+				return
+			}
+		}
+	}
+}
+
+// readEdits ::: Read edits from the connection (conn) until it closes or (ctx) is done, each one merged into those before it.
+func readEdits(ctx context.Context, conn *websocket.Conn, edits chan<- liveEdit) {
+	defer close(edits)
+
+	var sub Submit
+	for {
+		edit := liveEdit{Submit: sub}
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(msg, &edit); err != nil {
+			edit = liveEdit{Rev: edit.Rev, err: err}
+		} else {
+			sub = edit.Submit
+		}
+
+		select {
+		case edits <- edit:
+		case <-ctx.Done():
+			return // nobody is listening
+		}
+	}
+}
+
+// liveStart ::: Start a generation (gen) for an edit, returning what cancels it.
+func liveStart(ctx context.Context, gen int, edit liveEdit, q url.Values, results chan<- liveResult) context.CancelFunc {
+	genCtx, cancel := context.WithCancel(ctx)
+	go liveGenerate(genCtx, gen, edit, q, results)
+	return cancel
+}
+
+// liveGenerate ::: Build the Mesostic for an edit, unless (ctx) is cancelled by a newer one first.
+func liveGenerate(ctx context.Context, gen int, edit liveEdit, q url.Values, results chan<- liveResult) {
+	hTimer := prometheus.NewTimer(hpschdLiveTimer)
+	defer hTimer.ObserveDuration()
+
+	done := liveResult{gen: gen, reply: liveReply{Rev: edit.Rev}}
+
+	opts, err := edit.Submit.validate(q)
+	if err == nil {
+		done.res, err = mesostic.Generate(ctx, strings.NewReader(edit.Text), edit.SpineString, opts)
+	}
+	switch {
+	case ctx.Err() != nil:
+		hpschdLiveStale.Inc()
+		return
+	case err != nil:
+		var ae *apiError
+		if !errors.As(err, &ae) {
+			ae = badRequest("", "%s", err)
+		}
+		done.reply.Error = ae
+	default:
+		done.reply.Result = &done.res
+	}
+
+	select {
+	case results <- done:
+	case <-ctx.Done():
+		hpschdLiveStale.Inc()
+	}
+}
+
+// sameLines ::: How many lines at the start of (next) are drawn the same as in (prev).
+// A new SpineString column moves every line.
+func sameLines(prev, next mesostic.Result) int {
+	if prev.Pad != next.Pad {
+		return 0
+	}
+	n := 0
+	for n < len(prev.Lines) && n < len(next.Lines) {
+		p, q := prev.Lines[n], next.Lines[n]
+		if p.Data != q.Data || p.WChars != q.WChars || p.Spine != q.Spine || p.Break != q.Break {
+			break
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,177 @@
+/*
+
+	Live Composer Tests
+
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/maroda/hpschd/mesostic"
+)
+
+// TestTlive ::: Edits are merged and debounced, and only the newest one is answered.
+func TestTlive(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target live() :::\n")
+
+	rt := mux.NewRouter()
+	rt.HandleFunc("/live", live).Methods(http.MethodGet)
+	ts := httptest.NewServer(rt)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/live", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	read := func() liveReply {
+		t.Helper()
+		var reply liveReply
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	// the first edit has everything
+	text := "the quick brown\nfox jumps over\nthe lazy dog\n"
+	conn.WriteJSON(map[string]any{"rev": 1, "text": text, "spinestring": "cra"})
+	reply := read()
+	if reply.Rev != 1 || reply.Result == nil || reply.Result.Text != "      the quiCk b\nfox jumps oveR\n        the lAzy dog\n              \n" {
+		t.Fatalf("first reply %+v", reply)
+	}
+
+	// a burst of edits is answered once, for the last of them
+	spine := "c"
+	for rev := 2; rev <= 10; rev++ {
+		spine += "r"
+		conn.WriteJSON(map[string]any{"rev": rev, "spinestring": spine})
+	}
+	conn.WriteJSON(map[string]any{"rev": 11, "spinestring": "crt"})
+	reply = read()
+	if reply.Rev != 11 || reply.Result == nil || reply.Result.Spine != "crt" {
+		t.Fatalf("burst reply %+v", reply)
+	}
+	if lf := reply.Result.Lines[2]; reply.Same != 2 || lf.Spine+lf.East != "The lazy dog" {
+		t.Errorf("the text is kept from the first edit, %d lines the same:\n%s", reply.Same, reply.Result.Text)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * liveDebounce))
+	if err := conn.ReadJSON(&reply); err == nil {
+		t.Errorf("a stale edit was answered: %+v", reply)
+	}
+	conn.Close()
+
+	// a fresh connection, for errors
+	conn, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/live", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if reply := read(); reply.Error == nil || reply.Result != nil {
+		t.Errorf("bad edit %+v", reply)
+	}
+	conn.WriteJSON(map[string]any{"rev": 1, "text": text, "spinestring": "c-a"})
+	if reply := read(); reply.Rev != 1 || reply.Error == nil || reply.Error.Field != "spinestring" {
+		t.Errorf("bad spine %+v", reply)
+	}
+}
+
+// TestTreadEdits ::: The reader stops when the session has, even with an edit nobody will take.
+func TestTreadEdits(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target readEdits() :::\n")
+
+	finished := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := liveUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // the session is over
+		readEdits(ctx, conn, make(chan liveEdit))
+		close(finished)
+	}))
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(map[string]any{"rev": 1, "spinestring": "cra"})
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("readEdits is still waiting to send an edit")
+	}
+}
+
+// TestTliveGenerate ::: A cancelled generation never answers.
+func TestTliveGenerate(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target liveGenerate() :::\n")
+
+	results := make(chan liveResult)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	finished := make(chan struct{})
+	go func() {
+		liveGenerate(ctx, 1, liveEdit{Submit: Submit{Text: "the quick brown fox", SpineString: "fox"}}, url.Values{}, results)
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case done := <-results:
+		t.Errorf("cancelled generation answered %+v", done.reply)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled generation is still waiting")
+	}
+}
+
+// TestTsameLines ::: Lines drawn the same are counted from the start, a new SpineString column changes them all.
+func TestTsameLines(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target sameLines() :::\n")
+
+	gen := func(text, spine string) mesostic.Result {
+		res, err := mesostic.Generate(context.Background(), strings.NewReader(text), spine, mesostic.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	prev := gen("the cat\nsat on\nthe mat\n", "cat")
+	tests := []struct {
+		next mesostic.Result
+		want int
+	}{
+		{prev, len(prev.Lines)},
+		{gen("the cat\nsat on\nthe mat, too\n", "cat"), 2},
+		{gen("the cat\nsat on\nthe mat\nand more\n", "cat"), 4}, // the blank line for the end of the text is still blank
+		{gen("a long cat\nsat on\nthe mat\n", "cat"), 0},        // the column moved
+		{mesostic.Result{}, 0},
+	}
+	for _, tt := range tests {
+		if got := sameLines(prev, tt.next); got != tt.want {
+			t.Errorf("%q: %d lines the same, want %d", tt.next.Text, got, tt.want)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/maroda/hpschd/mesostic"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
@@ -39,9 +40,8 @@ func main() {
 		Confirm / initiate data locations
 
 		store ::: ephemeral mesostic cache
-		txrx ::: tmp scratch files
 	*/
-	datadirs := []string{"store", "txrx"}
+	datadirs := []string{"store"}
 	localDirs(datadirs)
 
 	// Fetching the NASA APOD for the homepage display is default behavior.
@@ -54,8 +54,14 @@ func main() {
 		apiKey := envVar("NASA_API_KEY", "DEMO_KEY")
 		apodURL := "https://api.nasa.gov/planetary/apod?api_key=" + apiKey
 
+		// Mesostic engine options for the APOD mesostics
+		opts, err := etlOptions()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to parse mesostic options")
+		}
+
 		log.Info().Msg("Fetching initial NASA APOD mesostic...")
-		NASAetl(apodURL)
+		NASAetl(apodURL, opts)
 		log.Info().Msg("Initial mesostic created, starting cronjob.")
 
 		timer := envVar("HPSCHD_TIMER", "77")
@@ -70,7 +76,7 @@ func main() {
 		//		increasingly EXISTENT (existing) mesostics trip up a fast fetch (e.g. 15s)
 		//		try ~11m for something that keeps things fresh enough
 		//		but will hopefully avoid the EXISTENT pileup
-		go fetchTicker(uint64(timerI))
+		go fetchTicker(uint64(timerI), opts)
 	}
 
 	// Prometheus
@@ -78,7 +84,10 @@ func main() {
 	prometheus.MustRegister(hpschdHomeTimer)
 	prometheus.MustRegister(hpschdJsubTimer)
 	prometheus.MustRegister(hpschdFsubTimer)
-	prometheus.MustRegister(hpschdMesolineTimer)
+	prometheus.MustRegister(hpschdLiveTimer)
+	prometheus.MustRegister(hpschdLiveStale)
+	prometheus.MustRegister(hpschdJobTimer)
+	prometheus.MustRegister(mesostic.MesolineTimer)
 	prometheus.MustRegister(hpschdNASAetlTimer)
 
 	// Deploy the web server
@@ -88,13 +97,36 @@ func main() {
 	rt.Handle("/metrics", promhttp.Handler())
 	rt.HandleFunc("/", homepage)
 	rt.HandleFunc("/ping", ping)
+	rt.HandleFunc("/composer", composer).Methods(http.MethodGet)
+	rt.HandleFunc("/live", live).Methods(http.MethodGet)
+
+	// Stored Mesostics
+	rt.HandleFunc("/store", storeList).Methods(http.MethodGet)
+	rt.HandleFunc("/store/{name}", storeMeso).Methods(http.MethodGet)
 
 	// API Features
 	api := rt.PathPrefix("/app").Subrouter()
 	api.HandleFunc("", JSubmit).Methods(http.MethodPost)       // JSON submission POST
 	api.HandleFunc("/{arg}", FSubmit).Methods(http.MethodPost) // Form submission POST
 
+	// Jobs, for sources too large to wait on
+	jq := newJobQueue(envInt("HPSCHD_JOB_QUEUE", "16"))
+	jq.start(envInt("HPSCHD_JOB_WORKERS", "2"))
+	rt.HandleFunc("/jobs", jq.submit).Methods(http.MethodPost)
+	rt.HandleFunc("/jobs/{id}", jq.status).Methods(http.MethodGet)
+	rt.HandleFunc("/jobs/{id}", jq.cancel).Methods(http.MethodDelete)
+	rt.HandleFunc("/jobs/{id}/result", jq.result).Methods(http.MethodGet)
+
 	if err := http.ListenAndServe(":9999", rt); err != nil {
 		log.Fatal().Err(err).Msg("startup failed!")
 	}
 }
+
+// envInt ::: A positive number from the environment variable (key), or (fallback).
+func envInt(key, fallback string) int {
+	n, err := strconv.Atoi(envVar(key, fallback))
+	if err != nil || n < 1 {
+		log.Fatal().Err(err).Str("key", key).Msg("Failed to parse a positive number")
+	}
+	return n
+}
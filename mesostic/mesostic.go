@@ -0,0 +1,952 @@
+/*
+
+	Mesostic Engine
+
+	A text is read line by line, or by another Segmentation, and each line is searched for the current SpineString character.
+	The SpineString rotates through its characters as lines are matched,
+	and the West and East fragments around each match are padded into the finished Mesostic.
+
+		res, err := mesostic.Generate(ctx, strings.NewReader(text), "cage", mesostic.Options{Algorithm: mesostic.Hundred})
+
+	Stream does the same for large sources, writing the Mesostic to an io.Writer.
+	The source is never held in memory, only the fragments of the Mesostic being built.
+
+*/
+
+package mesostic
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maroda/hpschd/iching"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Errors returned by the engine, test for them with errors.Is().
+var (
+	ErrEmptySpine       = errors.New("mesostic: empty spine string")
+	ErrUnknownAlgorithm = errors.New("mesostic: unknown algorithm")
+	ErrBadTolerance     = errors.New("mesostic: miss tolerance out of range")
+	ErrBadWidth         = errors.New("mesostic: wing width out of range")
+	ErrBadCycles        = errors.New("mesostic: cycle limit out of range")
+	ErrUnknownProcedure = errors.New("mesostic: unknown procedure")
+	ErrChanceProcedure  = errors.New("mesostic: chance only chooses line by line")
+)
+
+// MesolineTimer ::: Histogram for the runtime of mesoLine, for the caller to register.
+var MesolineTimer = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "hpschdMesolineTimer",
+	Help:    "Historgram for the runtime of mesoLine.",
+	Buckets: prometheus.LinearBuckets(0.001, 0.01, 50),
+})
+
+// Algorithm ::: The rule for placing the SpineString character in a line.
+// The values match the mesoLine() mode used once the character is found.
+type Algorithm int
+
+// Mesostic algorithms, the zero value of Options uses Fifty.
+const (
+	Fifty    Algorithm = 1 // 50% Mesostic
+	Hundred  Algorithm = 2 // 100% Mesostic
+	Acrostic Algorithm = 3 // Meso-Acrostic
+)
+
+// ParseAlgorithm ::: Convert an algorithm name into an Algorithm.
+// An empty name is the default 50% Mesostic.
+func ParseAlgorithm(a string) (Algorithm, error) {
+	switch strings.TrimSuffix(strings.TrimSpace(a), "%") {
+	case "", "50":
+		return Fifty, nil
+	case "100":
+		return Hundred, nil
+	case "acrostic", "meso-acrostic":
+		return Acrostic, nil
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownAlgorithm, a)
+}
+
+// String ::: The algorithm name accepted by ParseAlgorithm().
+func (a Algorithm) String() string {
+	switch a {
+	case Fifty:
+		return "50"
+	case Hundred:
+		return "100"
+	case Acrostic:
+		return "acrostic"
+	}
+	return fmt.Sprintf("Algorithm(%d)", int(a))
+}
+
+// MarshalText ::: Algorithms are written by name, e.g. in JSON.
+func (a Algorithm) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText ::: Algorithms are read by name, e.g. from JSON.
+func (a *Algorithm) UnmarshalText(text []byte) error {
+	alg, err := ParseAlgorithm(string(text))
+	if err != nil {
+		return err
+	}
+	*a = alg
+	return nil
+}
+
+// WingUnit ::: What the West and East wing widths count.
+type WingUnit int
+
+// Wing width units, the zero value of Options counts characters.
+const (
+	Chars WingUnit = iota // Characters, as printed
+	Words                 // Whitespace separated words, beyond the one holding the SpineString character
+)
+
+// ParseWingUnit ::: Convert a unit name into a WingUnit, an empty name is Chars.
+func ParseWingUnit(u string) (WingUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(u)) {
+	case "", "chars", "characters":
+		return Chars, nil
+	case "words":
+		return Words, nil
+	}
+	return 0, fmt.Errorf("%w: unknown unit %q", ErrBadWidth, u)
+}
+
+// String ::: The unit name accepted by ParseWingUnit().
+func (u WingUnit) String() string {
+	if u == Words {
+		return "words"
+	}
+	return "chars"
+}
+
+// Options ::: Settings for a single Mesostic, the zero value is a 50% Mesostic.
+type Options struct {
+	Algorithm      Algorithm // Rule for placing the SpineString character
+	Procedure      Procedure // How the source is walked, line by line, writing through its words, or diastic
+	FoldDiacritics bool      // SpineString "e" also matches "é", "è", "ë", and so on
+
+	/*
+		Miss tolerance ::: A SpineString character that is not found stays in place for the next line.
+		These give up on it and move to the next character, whichever comes first.
+		Zero values never give up, and a character that never appears blanks the rest of the Mesostic.
+	*/
+	MissLimit    int     // Lines in a row without the character
+	MissFraction float64 // Fraction of the source without the character, by size, when Stream can tell the size
+
+	/*
+		Wing widths ::: Cage kept the text on either side of the SpineString short.
+		The West wing is cut from the left and the East wing from the right, zero values keep everything.
+		In Words, the rest of the word holding the SpineString character is always kept.
+	*/
+	WestWidth int      // Most West wing characters or words, before the SpineString character
+	EastWidth int      // Most East wing characters or words, after the SpineString character
+	WingUnit  WingUnit // What the widths count
+
+	/*
+		Word boundaries ::: Wings cut in the middle of a word lose the broken word,
+		so the West wing starts and the East wing ends between words.
+		The word holding the SpineString character is never dropped.
+	*/
+	WordBoundaries bool // Drop broken words at the ends of the wings
+	AdjacentWords  bool // Keep only the word on either side of the SpineString word, the same as widths of 1 in Words
+
+	/*
+		Spine cycles ::: A cycle is complete when the last SpineString character is placed,
+		or skipped, and the rotation returns to the first character.
+	*/
+	Stanzas   bool // A blank line between cycles, each cycle is a stanza
+	MaxCycles int  // Stop reading the source after this many cycles, 0 reads all of it
+
+	Segment      Segmentation // How the source is split into Mesostic lines
+	SegmentWidth int          // Most characters in a segment, for Width
+
+	/*
+		Preprocessing ::: For sources typeset with hard line returns, applied before segmentation.
+		Blank lines still separate paragraphs.
+	*/
+	Unwrap      bool // Join the lines of each paragraph into one
+	Dehyphenate bool // Rejoin words broken by a hyphen at the end of a line, "exam-\nple" is "example"
+	Normalize   bool // Remove byte order marks and carriage returns, and normalize to NFKC, "ﬁ" is "fi"
+
+	/*
+		Chance ::: When a line holds the SpineString character more than once, line by line,
+		the I Ching chooses which one is used instead of taking the first.
+		The West wing starts after the one before it, so it never holds the character.
+		The other Procedures take the first SpineString character in a word, Chance fails with them.
+	*/
+	Chance bool  // Cast hexagrams to choose among the SpineString characters in a line
+	Seed   int64 // Seed for the casts, 0 picks one, the Result has the seed used
+}
+
+// Skip ::: A SpineString character given up on by the miss tolerance.
+type Skip struct {
+	Char  string `json:"char"`  // The SpineString character
+	Index int    `json:"index"` // Its position in the SpineString
+	Line  int    `json:"line"`  // The source line number where it was given up
+}
+
+// Result ::: A finished Mesostic.
+type Result struct {
+	Spine     string       `json:"spinestring"`    // The SpineString as given
+	Algorithm Algorithm    `json:"algorithm"`      // The algorithm used
+	Procedure Procedure    `json:"procedure"`      // How the source was walked
+	Segment   Segmentation `json:"segment"`        // How the source was split
+	Source    int          `json:"source"`         // Source segments read, lines unless segmented otherwise
+	Matched   int          `json:"matched"`        // Lines holding a SpineString character
+	Missed    int          `json:"missed"`         // Segments without a SpineString character
+	Cycles    int          `json:"cycles"`         // Complete cycles of the SpineString
+	Skipped   []Skip       `json:"skipped"`        // SpineString characters given up on, in order
+	Pad       int          `json:"pad"`            // Width of the longest WestSide, where the SpineString column sits
+	Seed      int64        `json:"seed,omitempty"` // With Chance, the seed that replays the casts
+	Lines     LineFrags    `json:"lines"`          // Every Mesostic line, in order
+	Text      string       `json:"text"`           // The Mesostic, padded and line returned (empty from Stream)
+}
+
+// LineFrag ::: Data model describing a processed LineFragment.
+// The West, Spine, and East fragments are lowercase except for the SpineString character.
+// Byte and Rune are offsets in the segment the line was built from, which is the source line only
+// with Lines and no preprocessing; other Segmentations collapse whitespace, so they do not map back to the source.
+type LineFrag struct {
+	Index   int    `json:"source"` // Segment number from the original text, the line number unless segmented otherwise.
+	LineNum int    `json:"line"`   // The assigned line number for these fragments.
+	WChars  int    `json:"width"`  // WestSide character count, including the SpineString character.
+	Data    string `json:"-"`      // The new Mesostic line.
+
+	West  string `json:"west"`  // WestSide fragment, before the SpineString character.
+	Spine string `json:"spine"` // The SpineString character, capitalized, empty on a miss.
+	East  string `json:"east"`  // EastSide fragment.
+	Byte  int    `json:"byte"`  // Byte offset of the SpineString character in the segment.
+	Rune  int    `json:"rune"`  // Rune offset of the SpineString character in the segment.
+	Miss  bool   `json:"miss"`  // The SpineString character was not found, the line is blank.
+
+	Stanza int  `json:"stanza"`          // The SpineString cycle holding this line, from 1.
+	Break  bool `json:"break,omitempty"` // With Stanzas, the line ends a stanza and a blank line follows.
+
+	Hexagram int               `json:"hexagram,omitempty"` // With Chance, the hexagram that chose among the SpineString characters in the line.
+	Cast     []iching.Hexagram `json:"cast,omitempty"`     // With Chance, every hexagram cast for the line with its lines, changing lines, and relating hexagram.
+}
+
+// Padding ::: The whitespace that lines this fragment up with the SpineString column at (pad).
+func (lf LineFrag) Padding(pad int) string {
+	return strings.Repeat(" ", max(0, pad-lf.WChars))
+}
+
+// LineFrags ::: The collection of LineFrag entries, in the order they were processed.
+type LineFrags []LineFrag
+
+// Builder ::: Owns the state of a single Mesostic while it is being built.
+// Nothing is shared between Builders, so any number of Mesostics can be generated concurrently.
+type Builder struct {
+	spine   []string  // SpineString characters from Spine()
+	mode    Algorithm // Mesostic algorithm mode
+	proc    Procedure // how the source is walked
+	bare    bool      // match characters without their diacritics
+	ictus   int       // SpineString character address
+	nexus   int       // Next SpineString character address
+	spaces  int       // Left-aligned whitespace for all lines
+	matched int       // lines holding a SpineString character
+	missed  int       // segments without a SpineString character
+	frags   LineFrags // line fragments, LineNum order
+
+	west, east int      // wing widths, 0 keeps everything
+	wingUnit   WingUnit // what the wing widths count
+	boundaries bool     // wings start and end between words
+
+	stanzas   bool // break the Mesostic between cycles
+	maxCycles int  // cycles before Done, 0 never stops
+	cycles    int  // complete cycles of the SpineString
+
+	oracle *iching.Oracle // casts hexagrams, with Chance
+	seed   int64          // the seed for the oracle
+
+	gap []string // words since the last SpineString word, walking words
+	hit *wordHit // SpineString word waiting for its East wing, walking words
+
+	missLimit int    // lines in a row before skipping, 0 never skips
+	missSize  int64  // bytes in a row before skipping, 0 never skips
+	misses    int    // lines in a row without the current character
+	missBytes int64  // bytes in a row without the current character
+	skipped   []Skip // characters given up on
+}
+
+// NewBuilder ::: A Builder for the SpineString (z) using the given Options.
+func NewBuilder(z string, opts Options) (*Builder, error) {
+	if opts.Algorithm == 0 {
+		opts.Algorithm = Fifty
+	}
+	if opts.Algorithm < Fifty || opts.Algorithm > Acrostic {
+		return nil, fmt.Errorf("%w %d", ErrUnknownAlgorithm, int(opts.Algorithm))
+	}
+	if opts.Procedure < LineByLine || opts.Procedure > Diastic {
+		return nil, fmt.Errorf("%w %d", ErrUnknownProcedure, int(opts.Procedure))
+	}
+	if opts.Chance && opts.Procedure != LineByLine {
+		return nil, fmt.Errorf("%w, not %s", ErrChanceProcedure, opts.Procedure)
+	}
+	if opts.MissLimit < 0 || opts.MissFraction < 0 || opts.MissFraction > 1 {
+		return nil, ErrBadTolerance
+	}
+	if opts.WestWidth < 0 || opts.EastWidth < 0 || opts.WingUnit < Chars || opts.WingUnit > Words {
+		return nil, ErrBadWidth
+	}
+	if opts.MaxCycles < 0 {
+		return nil, ErrBadCycles
+	}
+	if opts.Segment < Lines || opts.Segment > Paragraphs || opts.SegmentWidth < 0 || (opts.Segment == Width && opts.SegmentWidth == 0) {
+		return nil, ErrBadSegment
+	}
+
+	spine := Spine(z)
+	if len(spine) == 0 {
+		return nil, ErrEmptySpine
+	}
+	if opts.FoldDiacritics {
+		for i := range spine {
+			spine[i] = bare(spine[i])
+		}
+	}
+
+	if opts.AdjacentWords {
+		opts.WestWidth, opts.EastWidth, opts.WingUnit = 1, 1, Words
+	}
+
+	var oracle *iching.Oracle
+	if opts.Chance {
+		if opts.Seed == 0 {
+			opts.Seed = rand.Int64()
+		}
+		oracle = iching.New(opts.Seed)
+	}
+
+	return &Builder{
+		spine: spine,
+		mode:  opts.Algorithm,
+		proc:  opts.Procedure,
+		bare:  opts.FoldDiacritics,
+		nexus: 1 % len(spine),
+
+		west:       opts.WestWidth,
+		east:       opts.EastWidth,
+		wingUnit:   opts.WingUnit,
+		boundaries: opts.WordBoundaries,
+
+		stanzas:   opts.Stanzas,
+		maxCycles: opts.MaxCycles,
+
+		oracle: oracle,
+		seed:   opts.Seed,
+
+		missLimit: opts.MissLimit,
+	}, nil
+}
+
+// Generate ::: Build a Mesostic from the source text (r) using the SpineString (spine).
+// The context is checked between lines, a cancelled context returns its error.
+func Generate(ctx context.Context, r io.Reader, spine string, opts Options) (Result, error) {
+	var mesostic strings.Builder
+
+	res, err := Stream(ctx, &mesostic, r, spine, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	res.Text = mesostic.String()
+
+	return res, nil
+}
+
+// Stream ::: Build a Mesostic from the source text (r), reading one line at a time, and write it to (w).
+// Nothing is written until the whole source is read, the padding depends on the longest WestSide.
+// The returned Result has no Text, it has already been written.
+func Stream(ctx context.Context, w io.Writer, r io.Reader, spine string, opts Options) (Result, error) {
+	b, err := NewBuilder(spine, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if size := sourceSize(r); size > 0 && opts.MissFraction > 0 {
+		b.missSize = max(1, int64(opts.MissFraction*float64(size)))
+	}
+
+	var lnc int // segment counts for the Index
+	next := segmenter(prepare(r, opts), opts)
+	for !b.Done() {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		sline, more, rerr := next()
+		if rerr != nil {
+			return Result{}, fmt.Errorf("mesostic: reading source: %w", rerr)
+		}
+		if !more {
+			break
+		}
+
+		lnc++
+		if b.proc == LineByLine {
+			b.Line(sline, lnc)
+		} else {
+			b.WriteThrough(sline, lnc)
+		}
+	}
+	b.Flush()
+
+	if _, err := b.WriteTo(w); err != nil {
+		return Result{}, fmt.Errorf("mesostic: writing: %w", err)
+	}
+
+	return Result{
+		Spine:     spine,
+		Algorithm: b.mode,
+		Procedure: b.proc,
+		Segment:   opts.Segment,
+		Source:    lnc,
+		Matched:   b.matched,
+		Missed:    b.missed,
+		Cycles:    b.cycles,
+		Skipped:   b.Skipped(),
+		Pad:       b.spaces,
+		Seed:      b.seed,
+		Lines:     b.Lines(),
+	}, nil
+}
+
+// sourceSize ::: The size in bytes of sources that can tell, or 0.
+func sourceSize(r io.Reader) int64 {
+	switch src := r.(type) {
+	case interface{ Size() int64 }: // strings.Reader, bytes.Reader
+		return src.Size()
+	case interface{ Len() int }: // bytes.Buffer
+		return int64(src.Len())
+	case interface{ Stat() (fs.FileInfo, error) }: // os.File
+		if fi, err := src.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+	return 0
+}
+
+// Spine ::: Process the SpineString
+//
+//	Construct a slice of lowercase SpineString characters that can be rotated by Ictus().
+//	Characters are graphemes, so "Müller" and "Σαπφώ" split into letters, not bytes.
+func Spine(z string) []string {
+	var zch []string
+
+	for _, g := range graphemes(z) {
+		zch = append(zch, fold(g))
+	}
+	return zch
+}
+
+// graphemes ::: Split (s) into the characters a reader sees.
+// A character is a base rune followed by any combining marks, e.g. "e\u0301" is one character.
+func graphemes(s string) []string {
+	var gs []string
+
+	start := 0
+	for i, r := range s {
+		if i > 0 && !isMark(r) {
+			gs = append(gs, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		gs = append(gs, s[start:])
+	}
+	return gs
+}
+
+// isMark ::: Runes that belong to the character before them.
+func isMark(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) || r == '\u200d'
+}
+
+// fold ::: The lowercase, composed (NFC) form of a character used for matching.
+// Going through uppercase first catches letters with more than one lowercase form, like the Greek final sigma.
+func fold(c string) string {
+	if len(c) == 1 && c[0] < utf8.RuneSelf {
+		return strings.ToLower(c)
+	}
+	return strings.ToLower(strings.ToUpper(norm.NFC.String(c)))
+}
+
+// bareLetters ::: Letters with a stroke or other mark that Unicode does not decompose.
+var bareLetters = map[rune]rune{
+	'ø': 'o',
+	'ł': 'l',
+	'đ': 'd',
+	'ħ': 'h',
+	'ı': 'i',
+	'ŧ': 't',
+}
+
+// bare ::: A folded character without its diacritics, "é", "è", and "ë" are all "e".
+func bare(c string) string {
+	if len(c) == 1 && c[0] < utf8.RuneSelf {
+		return c
+	}
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(c) {
+		if isMark(r) {
+			continue
+		}
+		if base, ok := bareLetters[r]; ok {
+			r = base
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// width ::: The number of printed columns in (s), leaving out combining marks and invisible format runes like the BOM.
+func width(s string) int {
+	var w int
+	for _, r := range s {
+		if !isMark(r) && !unicode.Is(unicode.Cf, r) {
+			w++
+		}
+	}
+	return w
+}
+
+// mesoLine ::: finds the current SpineString character in the current line
+//
+// The West Fragment is everything to the left of the SpineString character.
+// The East Fragment is everything to the right of the SpineString character.
+//
+//	s == the current line to process
+//	c == line number
+//
+// The SpineString characters, algorithm mode, ictus, nexus,
+// and left-aligned whitespace all belong to the Builder.
+// Characters are lowercased as they are stacked, so offsets are those of the segment (s).
+func (b *Builder) mesoLine(s string, c int) bool {
+	hTimer := prometheus.NewTimer(MesolineTimer)
+	defer hTimer.ObserveDuration()
+
+	z := b.spine    // slice of SpineString characters
+	ict := &b.ictus // ictus of the SpineString characters
+	nex := &b.nexus // next ictus (not always ict + 1)
+
+	var wstack []string // slice for rebuilding the west fragment
+	var estack []string // slice for rebuilding the east fragment
+	var found bool      // the character was found in this line
+	mode := 0           // the Mesostic algorithm mode, always starts with 0
+
+	chars := graphemes(s)         // characters, not bytes
+	skip, cast := b.chance(chars) // characters before the West fragment
+
+CharLoop:
+	// step through the current string and process mesostic rules
+	for i := skip; i < len(chars); i++ {
+		key := fold(chars[i]) // compared with the SpineString characters
+		if b.bare {
+			key = bare(key)
+		}
+		char := strings.ToLower(chars[i])
+
+		/*
+			WestSide ::: Everything to the LEFT AND INCLUDING the SpineString, this is mode 0
+			EastSide ::: Everything to the RIGHT AND NOT the SpineString, includes:
+
+			mode 1 50% Mesostic ::: No occurance of the current Spine String Character in back of it.
+			mode 2 100% Mesostic ::: No occurance of the current Spine String Character in back OR in front of it.
+			mode 3 Meso-Acrostic ::: No pre/post rules, any character can appear before or after the Spine String Char.
+		*/
+		switch mode {
+		case 0:
+			switch {
+			case key != z[*ict]:
+				// not found
+				log.Debug().Str("z", z[*ict]).Msg("notfound")
+				if i == len(chars)-1 {
+					// last character of the line
+					if key != z[*ict] {
+						// the final character is not the SpineString
+						// in this version, the line is thrown out
+						// in future versions, the line may only be thrown out
+						// if a certain tolerance is met
+						// e.g.: the number of false successes
+						log.Debug().Str("SSCHAR", z[*ict]).Str("LNCHAR", char).Msg("EOL")
+						found = false
+						wstack = nil
+						break CharLoop // We're done.
+					}
+				}
+				wstack = append(wstack, char)
+			case key == z[*ict]:
+				// SpineString hit!
+				log.Debug().Str("z", z[*ict]).Msg("spinestr")
+				found = true
+				char = strings.ToUpper(char)  // Spine Character is capitalized
+				wstack = append(wstack, char) // Appended to the string
+				mode = int(b.mode)
+				break // re-evaluate the switch with mode set
+			}
+		case 1:
+			/*
+				Allow the current SSchar in the remainder of the line,
+				but do not print anything on this line at or beyond the next SSchar
+				because that will appear on the next line and cannot have itself preceeding it.
+
+				This method preserves the line returns found in the source.
+			*/
+			if key != z[*nex] {
+				estack = append(estack, char)
+			} else {
+				break CharLoop // We're done.
+			}
+		case 2:
+			/*
+				The current SSchar cannot appear again before the next SSchar,
+				so the EastSide is trimmed at whichever of the two comes first.
+				The WestSide never holds the current SSchar, mode 0 stops on the first one.
+			*/
+			if key != z[*nex] && key != z[*ict] {
+				estack = append(estack, char)
+			} else {
+				break CharLoop // We're done.
+			}
+		case 3:
+			// No rules for the EastSide, the remainder of the line is kept.
+			estack = append(estack, char)
+		}
+	}
+
+	// Post processing
+	frag := LineFrag{Index: c, LineNum: len(b.frags) + 1, Miss: !found, Stanza: b.cycles + 1, Cast: cast}
+	if cast != nil {
+		frag.Hexagram = cast[0].Number
+	}
+
+	// Wing widths, the SpineString character stays at the end of the WestSide
+	if found {
+		pos := len(wstack) - 1 // the SpineString character's place in the West fragment
+		prefix := strings.Join(chars[:skip+pos], "")
+
+		west := westWing(wstack[:pos], b.west, b.wingUnit)
+		east := eastWing(estack, b.east, b.wingUnit)
+		if b.boundaries {
+			west = westBoundary(wstack[:pos], west)
+			east = eastBoundary(chars[skip+pos+1:], east)
+		}
+
+		frag.West = strings.Join(west, "") // WestSide fragment
+		frag.Spine = wstack[pos]           // SpineString character
+		frag.East = strings.Join(east, "") // EastSide fragment
+		frag.Byte = len(prefix)
+		frag.Rune = utf8.RuneCountInString(prefix)
+	}
+	frag.WChars = width(frag.West + frag.Spine)
+	frag.Data = frag.West + frag.Spine + frag.East
+
+	// Add results to the end of the fragments
+	b.frags = append(b.frags, frag)
+
+	// record the longest WestSide fragment length
+	if frag.WChars > b.spaces {
+		b.spaces = frag.WChars
+	}
+
+	return found
+}
+
+// chance ::: With Chance, cast hexagrams to choose among the current SpineString characters in the line (chars).
+// Returns how many characters come before the West fragment, the ones up to and including the previous
+// SpineString character, and the hexagrams cast, none when there was nothing to choose.
+// More than 64 characters take more than one hexagram, see Oracle.IntN().
+func (b *Builder) chance(chars []string) (int, []iching.Hexagram) {
+	if b.oracle == nil {
+		return 0, nil
+	}
+
+	var at []int
+	for i, c := range chars {
+		if b.key(c) == b.spine[b.ictus] {
+			at = append(at, i)
+		}
+	}
+	if len(at) < 2 {
+		return 0, nil
+	}
+
+	pick := b.oracle.IntN(len(at))
+	cast := slices.Clone(b.oracle.Last()) // the oracle reuses it
+	if pick == 0 {
+		return 0, cast
+	}
+	return at[pick-1] + 1, cast
+}
+
+// westWing ::: Keep the last (n) characters or words of the West wing (w), 0 keeps everything.
+func westWing(w []string, n int, u WingUnit) []string {
+	if n == 0 {
+		return w
+	}
+	if u == Chars {
+		return w[max(0, len(w)-n):]
+	}
+
+	// the rest of the SpineString word, then (n) words before it
+	i := len(w)
+	for i > 0 && !isSpace(w[i-1]) {
+		i--
+	}
+	for ; n > 0; n-- {
+		j := i
+		for j > 0 && isSpace(w[j-1]) {
+			j--
+		}
+		if j == 0 {
+			break
+		}
+		for j > 0 && !isSpace(w[j-1]) {
+			j--
+		}
+		i = j
+	}
+	return w[i:]
+}
+
+// eastWing ::: Keep the first (n) characters or words of the East wing (e), 0 keeps everything.
+func eastWing(e []string, n int, u WingUnit) []string {
+	if n == 0 {
+		return e
+	}
+	if u == Chars {
+		return e[:min(len(e), n)]
+	}
+
+	// the rest of the SpineString word, then (n) words after it
+	i := 0
+	for i < len(e) && !isSpace(e[i]) {
+		i++
+	}
+	for ; n > 0; n-- {
+		j := i
+		for j < len(e) && isSpace(e[j]) {
+			j++
+		}
+		if j == len(e) {
+			break
+		}
+		for j < len(e) && !isSpace(e[j]) {
+			j++
+		}
+		i = j
+	}
+	return e[:i]
+}
+
+// westBoundary ::: Drop a broken word from the start of the West wing (kept), cut from the end of (full).
+func westBoundary(full, kept []string) []string {
+	k := len(full) - len(kept)
+	if k == 0 || len(kept) == 0 || isSpace(full[k-1]) || isSpace(kept[0]) {
+		return kept
+	}
+
+	for i := range kept {
+		if isSpace(kept[i]) {
+			for i < len(kept) && isSpace(kept[i]) {
+				i++
+			}
+			return kept[i:]
+		}
+	}
+	return kept // all of it belongs to the SpineString word
+}
+
+// eastBoundary ::: Drop a broken word from the end of the East wing (kept), cut from the start of (rest).
+func eastBoundary(rest, kept []string) []string {
+	k := len(kept)
+	if k == 0 || k == len(rest) || isSpace(rest[k]) || isSpace(kept[k-1]) {
+		return kept
+	}
+
+	for i := k - 1; i >= 0; i-- {
+		if isSpace(kept[i]) {
+			for i > 0 && isSpace(kept[i-1]) {
+				i--
+			}
+			return kept[:i]
+		}
+	}
+	return kept // all of it belongs to the SpineString word
+}
+
+// isSpace ::: The character (c) separates words.
+func isSpace(c string) bool {
+	r, _ := utf8.DecodeRuneInString(c)
+	return unicode.IsSpace(r)
+}
+
+// Ictus ::: Enables the rotation of SpineString characters by operating on the index.
+//
+//	lss == length of SpineString
+//	isp == pointer to the ictus
+//	nsp == pointer to the next ictus
+func Ictus(lss int, isp *int, nsp *int) {
+	// a mesostic line has been finished,
+	// increase ictus, i.e. the current character position
+	if *isp < lss-1 {
+		*isp++
+	} else if *isp == lss-1 { // last element, rotate
+		*isp = 0
+	}
+
+	// this is used for lookahead
+	//  update nexus, i.e. the next character position
+	//	 if we've hit the end, we wrap to the first character
+	if *isp == lss-1 { // now last element, next is 0
+		*nsp = 0
+	} else {
+		*nsp = *isp + 1
+	}
+}
+
+// Preus ::: Ictus, but rewind.
+func Preus(lss int, isp *int, nsp *int) {
+	if *isp > 0 { // not first element, simple subtraction
+		*isp--
+	} else { // now first element, rotate backwards
+		*isp = lss - 1
+	}
+
+	// nexus is still calcualted the same way
+	if *isp == lss-1 {
+		*nsp = 0
+	} else {
+		*nsp = *isp + 1
+	}
+}
+
+// Line ::: Process one line (s) of the source text with line number (c).
+//
+//	mesoLine() adds to the Builder's fragments, returning a boolean success status.
+//
+//	If the SpineString character was found,
+//		Ictus() rotates the SpineString position forward one spot,
+//		and the next line processed will have the next character to match.
+//	If the SpineString character wasn't found,
+//		rewind the SpineString with Preus(),
+//		making the SpineString character "stay in place", so it is matched in the next line.
+//
+//		If the SSchar is never found, the Mesostic will be effectively blank,
+//		unless the miss tolerance is met: then it is skipped and the rotation goes forward.
+//
+//	Moving forward from the last SpineString character completes a cycle and ends the stanza.
+func (b *Builder) Line(s string, c int) bool {
+	last := b.ictus == len(b.spine)-1
+	success := b.mesoLine(s, c)
+	forward := success
+	if success {
+		b.matched++
+		b.misses, b.missBytes = 0, 0
+	} else {
+		b.missed++
+		b.misses++
+		b.missBytes += int64(len(s)) + 1 // with its line return
+		if b.tolerance() {
+			b.skipped = append(b.skipped, Skip{Char: b.spine[b.ictus], Index: b.ictus, Line: c})
+			b.misses, b.missBytes = 0, 0
+			forward = true
+
+			log.Debug().Str("SSCHAR", b.spine[b.ictus]).Int("lnc", c).Msg("skipped")
+		} else {
+			Preus(len(b.spine), &b.ictus, &b.nexus)
+		}
+	}
+	Ictus(len(b.spine), &b.ictus, &b.nexus)
+
+	if forward && last {
+		b.cycles++
+		b.frags[len(b.frags)-1].Break = b.stanzas
+	}
+
+	log.Debug().
+		Int("lnc", c).
+		Int("ictus", b.ictus).
+		Int("spaces", b.spaces).
+		Bool("success", success).
+		Msg("")
+
+	return success
+}
+
+// tolerance ::: The current SpineString character has been missed long enough to skip it.
+func (b *Builder) tolerance() bool {
+	return (b.missLimit > 0 && b.misses >= b.missLimit) ||
+		(b.missSize > 0 && b.missBytes >= b.missSize)
+}
+
+// Cycles ::: The complete cycles of the SpineString so far.
+func (b *Builder) Cycles() int {
+	return b.cycles
+}
+
+// Done ::: The cycle limit has been reached, no more lines are needed.
+func (b *Builder) Done() bool {
+	return b.maxCycles > 0 && b.cycles >= b.maxCycles
+}
+
+// Skipped ::: The SpineString characters given up on so far.
+func (b *Builder) Skipped() []Skip {
+	return b.skipped
+}
+
+// Lines ::: The Mesostic lines built so far.
+func (b *Builder) Lines() LineFrags {
+	return b.frags
+}
+
+// Mesostic ::: Print the fragments collected so far into the finished Mesostic.
+func (b *Builder) Mesostic() string {
+	var mesostic strings.Builder
+	b.WriteTo(&mesostic)
+	return mesostic.String()
+}
+
+// WriteTo ::: Write the fragments collected so far to (w), one padded line at a time.
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+
+	var n int64
+	for i, frag := range b.frags {
+		// define 'West Side' whitespace as
+		//  (length of the longest fragment) - (length of the current fragment)
+		printspace := frag.Padding(b.spaces)
+
+		// format the new line with leading whitespace and trailing line return
+		wn, err := fmt.Fprintf(bw, "%s%s\n", printspace, frag.Data)
+		n += int64(wn)
+		if err != nil {
+			return n, err
+		}
+
+		// stanza break, but not after the final line
+		if frag.Break && i < len(b.frags)-1 {
+			wn, err := bw.WriteString("\n")
+			n += int64(wn)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, bw.Flush()
+}
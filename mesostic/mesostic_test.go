@@ -0,0 +1,670 @@
+/*
+
+	Mesostic Engine Tests
+
+*/
+
+package mesostic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/maroda/hpschd/iching"
+)
+
+// TestTGenerate ::: Create a mesostic from a static source file and match the known result.
+func TestTGenerate(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Generate() :::\n")
+
+	source, err := os.Open("../sources/lorenipsum-plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	res, err := Generate(context.Background(), source, "craque", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(res.Text)
+
+	// the stored copy was written by the API, which adds a final line return
+	want, err := os.ReadFile("../sources/lorenipsum-craque.mesostic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text+"\n" != string(want) {
+		t.Errorf("Mesostic does not match the stored copy:\n%s", res.Text)
+	}
+
+	if res.Source != 10 || res.Matched != 9 || res.Missed != 1 || res.Algorithm != Fifty {
+		t.Errorf("Result lines %d, matched %d, missed %d, algorithm %s", res.Source, res.Matched, res.Missed, res.Algorithm)
+	}
+}
+
+// TestTResultLines ::: Every Mesostic line comes back with its fragments and where they were found.
+func TestTResultLines(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Result.Lines :::\n")
+
+	res, err := Generate(context.Background(), strings.NewReader("the quick brown\nno match\nfox jumps övr\nThe lazy dog"), "cra", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := LineFrags{
+		{Index: 1, LineNum: 1, WChars: 8, Data: "the quiCk b", West: "the qui", Spine: "C", East: "k b", Byte: 7, Rune: 7, Stanza: 1},
+		{Index: 2, LineNum: 2, Miss: true, Stanza: 1},
+		{Index: 3, LineNum: 3, WChars: 13, Data: "fox jumps övR", West: "fox jumps öv", Spine: "R", Byte: 13, Rune: 12, Stanza: 1},
+		{Index: 4, LineNum: 4, WChars: 6, Data: "the lAzy dog", West: "the l", Spine: "A", East: "zy dog", Byte: 5, Rune: 5, Stanza: 1},
+	}
+	if len(res.Lines) != len(want) {
+		t.Fatalf("%d lines, want %d", len(res.Lines), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(res.Lines[i], want[i]) {
+			t.Errorf("line %d:\n%+v\nwant:\n%+v", i+1, res.Lines[i], want[i])
+		}
+	}
+
+	// the text is rendered from the same lines
+	var text strings.Builder
+	for _, lf := range res.Lines {
+		text.WriteString(lf.Padding(res.Pad) + lf.Data + "\n")
+	}
+	if res.Pad != 13 || text.String() != res.Text {
+		t.Errorf("pad %d, text from lines:\n%s\nwant:\n%s", res.Pad, text.String(), res.Text)
+	}
+}
+
+// checkRule ::: Every line of a 50% or 100% Mesostic (res) keeps the rule, none of the SpineString characters were skipped.
+// The West wing never holds the line's SpineString character, the East wing stops before the next one,
+// and at 100% the East wing does not hold the line's own either.
+func checkRule(t *testing.T, res Result) {
+	t.Helper()
+
+	spine := Spine(res.Spine)
+	n := 0 // lines holding a SpineString character
+	for _, lf := range res.Lines {
+		if lf.Miss {
+			continue
+		}
+		key, next := spine[n%len(spine)], spine[(n+1)%len(spine)]
+		n++
+
+		switch {
+		case fold(lf.Spine) != key:
+			t.Errorf("line %d %q has %q, want %q", lf.LineNum, lf.Data, lf.Spine, key)
+		case strings.Contains(lf.West, key):
+			t.Errorf("line %d %q has %q in the West wing", lf.LineNum, lf.Data, key)
+		case strings.Contains(lf.East, next):
+			t.Errorf("line %d %q has the next %q in the East wing", lf.LineNum, lf.Data, next)
+		case res.Algorithm == Hundred && strings.Contains(lf.East, key):
+			t.Errorf("line %d %q has %q again in the East wing", lf.LineNum, lf.Data, key)
+		}
+	}
+}
+
+// TestTStream ::: Stream a large source file straight through to a writer.
+func TestTStream(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Stream() :::\n")
+
+	source, err := os.Open("../sources/u2k.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	var out strings.Builder
+	res, err := Stream(context.Background(), &out, source, "ulysses", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one Mesostic line for every source line, including the empty one after the last line return
+	if res.Source != 1927 {
+		t.Errorf("Stream read %d lines, want 1927", res.Source)
+	}
+	if n := strings.Count(out.String(), "\n"); n != res.Source {
+		t.Errorf("Stream wrote %d lines for %d source lines", n, res.Source)
+	}
+	if res.Matched == 0 || res.Text != "" {
+		t.Errorf("Stream matched %d lines, returned %d bytes of Text", res.Matched, len(res.Text))
+	}
+	checkRule(t, res)
+
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	hundred, err := Stream(context.Background(), io.Discard, source, "ulysses", Options{Algorithm: Hundred})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRule(t, hundred)
+}
+
+// TestTMissTolerance ::: A SpineString character that never appears is skipped instead of stalling.
+func TestTMissTolerance(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.MissLimit :::\n")
+
+	source, err := os.ReadFile("../sources/lorenipsum-plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// there is no z in the source, so everything after it stalls
+	stalled, err := Generate(context.Background(), strings.NewReader(string(source)), "czra", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stalled.Matched != 1 || len(stalled.Skipped) != 0 {
+		t.Errorf("without tolerance matched %d, skipped %v", stalled.Matched, stalled.Skipped)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"lines", Options{MissLimit: 2}},
+		{"fraction", Options{MissFraction: 0.1}},
+	}
+	for _, tt := range tests {
+		res, err := Generate(context.Background(), strings.NewReader(string(source)), "czra", tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Skipped) == 0 || res.Skipped[0].Char != "z" || res.Skipped[0].Index != 1 {
+			t.Errorf("%s: skipped %v, want z first", tt.name, res.Skipped)
+		}
+		for _, sk := range res.Skipped {
+			if sk.Char != "z" {
+				t.Errorf("%s: skipped %v, which is in the source", tt.name, sk)
+			}
+		}
+		if res.Matched <= stalled.Matched {
+			t.Errorf("%s: matched %d lines, no more than without tolerance", tt.name, res.Matched)
+		}
+	}
+
+	if _, err := Generate(context.Background(), strings.NewReader("text"), "t", Options{MissFraction: 2}); !errors.Is(err, ErrBadTolerance) {
+		t.Errorf("MissFraction 2 returned %v", err)
+	}
+}
+
+// TestTWingWidth ::: West and East wings are cut to the configured widths, and the padding follows.
+func TestTWingWidth(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.WestWidth/EastWidth :::\n")
+
+	source, err := os.ReadFile("../sources/lorenipsum-plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Generate(context.Background(), strings.NewReader(string(source)), "craque", Options{WestWidth: 8, EastWidth: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every matched line has its SpineString character in the ninth column
+	for _, line := range strings.Split(strings.TrimRight(res.Text, " \n"), "\n") {
+		chars := graphemes(line)
+		if len(chars) < 9 || chars[8] != strings.ToUpper(chars[8]) || len(chars) > 14 {
+			t.Errorf("line %q is not cut to 8 + 1 + 5", line)
+		}
+	}
+
+	words, err := Generate(context.Background(), strings.NewReader("lorem ipsum dolor sit amet, consectetur adipiscing elit"), "c",
+		Options{Algorithm: Acrostic, WestWidth: 1, EastWidth: 1, WingUnit: Words})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words.Text != "amet, Consectetur adipiscing\n" {
+		t.Errorf("word wings %q", words.Text)
+	}
+
+	if _, err := ParseWingUnit("lines"); !errors.Is(err, ErrBadWidth) {
+		t.Errorf("ParseWingUnit(\"lines\") returned %v", err)
+	}
+}
+
+// TestTWordBoundaries ::: Wings lose broken words, but never the SpineString word.
+func TestTWordBoundaries(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.WordBoundaries :::\n")
+
+	source, err := os.ReadFile("../sources/lorenipsum-plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Generate(context.Background(), strings.NewReader(string(source)), "craque", Options{WordBoundaries: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(res.Text, "\n")
+	want := map[int]string{
+		0: "lorem ipsum dolor sit amet, Consectetu", // the SpineString word is kept
+		1: "elit, sed do eiusmod tempoR incididunt ut",
+		2: "dolore mAgna",
+	}
+	for i, w := range want {
+		if strings.TrimSpace(lines[i]) != w {
+			t.Errorf("line %d is %q, want %q", i, strings.TrimSpace(lines[i]), w)
+		}
+	}
+
+	// West wings cut by width start on a word too
+	cut, err := Generate(context.Background(), strings.NewReader("lorem ipsum dolor sit amet, consectetur"), "c",
+		Options{Algorithm: Acrostic, WestWidth: 8, EastWidth: 6, WordBoundaries: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cut.Text != "amet, Consect\n" {
+		t.Errorf("cut wings %q", cut.Text)
+	}
+
+	adjacent, err := Generate(context.Background(), strings.NewReader("lorem ipsum dolor sit amet, consectetur adipiscing elit"), "c",
+		Options{Algorithm: Acrostic, AdjacentWords: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adjacent.Text != "amet, Consectetur adipiscing\n" {
+		t.Errorf("adjacent words %q", adjacent.Text)
+	}
+}
+
+// TestTStanzas ::: Each cycle of the SpineString is a stanza, and the source can stop after a few.
+func TestTStanzas(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.Stanzas :::\n")
+
+	source := "a cat\nthe bat\nno match\nat last\nthe cab\nbat again\n"
+
+	res, err := Generate(context.Background(), strings.NewReader(source), "ab", Options{Stanzas: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "      A cat\n  the B\n\n  no mAtch\n       \nthe caB\n\n     bAt again\n       \n"
+	if res.Text != want {
+		t.Errorf("stanzas %q, want %q", res.Text, want)
+	}
+	stanzas := []int{1, 1, 2, 2, 2, 3, 3}
+	if len(res.Lines) != len(stanzas) {
+		t.Fatalf("%d lines, want %d", len(res.Lines), len(stanzas))
+	}
+	for i, lf := range res.Lines {
+		if lf.Stanza != stanzas[i] {
+			t.Errorf("line %d in stanza %d, want %d", i+1, lf.Stanza, stanzas[i])
+		}
+	}
+	if res.Cycles != 2 || !res.Lines[1].Break || !res.Lines[4].Break {
+		t.Errorf("cycles %d, lines %+v", res.Cycles, res.Lines)
+	}
+
+	// stop after the first cycle
+	one, err := Generate(context.Background(), strings.NewReader(source), "ab", Options{Stanzas: true, MaxCycles: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if one.Text != "    A cat\nthe B\n" || one.Source != 2 || one.Cycles != 1 {
+		t.Errorf("one cycle %q, %d source lines, %d cycles", one.Text, one.Source, one.Cycles)
+	}
+
+	if _, err := Generate(context.Background(), strings.NewReader(source), "ab", Options{MaxCycles: -1}); !errors.Is(err, ErrBadCycles) {
+		t.Errorf("MaxCycles -1: %v", err)
+	}
+}
+
+// TestTChance ::: The I Ching chooses among the SpineString characters in a line, and the seed replays it.
+func TestTChance(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.Chance :::\n")
+
+	source := "a cab, a bat, a bob, a bib\n"
+
+	// without Chance the first one is used
+	first, err := Generate(context.Background(), strings.NewReader(source), "b", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Lines[0].Byte != 4 || first.Lines[0].Hexagram != 0 || first.Lines[0].Cast != nil || first.Seed != 0 {
+		t.Errorf("without chance %+v, seed %d", first.Lines[0], first.Seed)
+	}
+
+	offsets := make(map[int]bool)
+	for seed := range int64(40) {
+		res, err := Generate(context.Background(), strings.NewReader(source), "b", Options{Chance: true, Seed: seed + 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lf := res.Lines[0]
+		if res.Seed != seed+1 || lf.Hexagram < 1 || lf.Hexagram > 64 {
+			t.Errorf("seed %d: %d, hexagram %d", seed+1, res.Seed, lf.Hexagram)
+		}
+		if len(lf.Cast) != 1 || !reflect.DeepEqual(lf.Cast[0], iching.FromLines(lf.Cast[0].Lines)) || lf.Cast[0].Number != lf.Hexagram {
+			t.Errorf("seed %d: hexagram %d, cast %+v", seed+1, lf.Hexagram, lf.Cast)
+		}
+		if source[lf.Byte] != 'b' || strings.Contains(lf.West, "b") {
+			t.Errorf("seed %d: %+v", seed+1, lf)
+		}
+		offsets[lf.Byte] = true
+
+		again, _ := Generate(context.Background(), strings.NewReader(source), "b", Options{Chance: true, Seed: seed + 1})
+		if again.Text != res.Text || !reflect.DeepEqual(again.Lines[0], lf) {
+			t.Errorf("seed %d made %q, then %q", seed+1, res.Text, again.Text)
+		}
+	}
+	if len(offsets) < 3 {
+		t.Errorf("40 seeds chose %d of the SpineString characters", len(offsets))
+	}
+
+	// a seed is picked when none is given
+	res, err := Generate(context.Background(), strings.NewReader(source), "b", Options{Chance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Seed == 0 {
+		t.Error("no seed recorded")
+	}
+
+	// the words are walked without chance
+	for _, proc := range []Procedure{WritingThrough, Diastic} {
+		if _, err := Generate(context.Background(), strings.NewReader(source), "b", Options{Procedure: proc, Chance: true, Seed: 1}); !errors.Is(err, ErrChanceProcedure) {
+			t.Errorf("%s with chance: %v", proc, err)
+		}
+	}
+}
+
+// TestTGenerateErrors ::: Bad input is returned as a typed error, not a panic.
+func TestTGenerateErrors(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Generate() errors :::\n")
+
+	if _, err := Generate(context.Background(), strings.NewReader("text"), "", Options{}); !errors.Is(err, ErrEmptySpine) {
+		t.Errorf("empty spine returned %v", err)
+	}
+
+	if _, err := Generate(context.Background(), strings.NewReader("text"), "t", Options{Algorithm: 9}); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("unknown algorithm returned %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Generate(ctx, strings.NewReader("text"), "t", Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context returned %v", err)
+	}
+}
+
+// TestTIctus ::: Using a specific string, test this function's ability to rotate through each character.
+func TestTIctus(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Ictus() :::\n")
+
+	spineString := "cra"
+	ictus := 0
+	nexus := 1
+
+	var spineChars []string
+	for h := 0; h < len(spineString); h++ {
+		spineChars = append(spineChars, strings.ToLower(string(spineString[h])))
+	}
+
+	// Test each character and then that it rotates to the first successfully.
+	if spineChars[ictus] != "c" {
+		t.Errorf("%q\n", spineString)
+	}
+
+	Ictus(len(spineString), &ictus, &nexus)
+
+	if spineChars[ictus] != "r" {
+		t.Errorf("%q\n", spineString)
+	}
+
+	Ictus(len(spineString), &ictus, &nexus)
+
+	if spineChars[ictus] != "a" {
+		t.Errorf("%q\n", spineString)
+	}
+
+	Ictus(len(spineString), &ictus, &nexus)
+
+	if spineChars[ictus] != "c" {
+		t.Errorf("Rotation failed! %q\n", spineString)
+	}
+}
+
+// TestTPreus ::: Using a specific string, test this function's ability to rewind the SpineString rotation done by Ictus().
+func TestTPreus(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Preus() :::\n")
+
+	spineString := "cra"
+	ictus := 0
+	nexus := 1
+
+	var spineChars []string
+	for h := 0; h < len(spineString); h++ {
+		spineChars = append(spineChars, strings.ToLower(string(spineString[h])))
+	}
+
+	// Test each character and then that it rewinds to the one before it.
+	if spineChars[ictus] != "c" {
+		t.Errorf("%q\n", spineString)
+	}
+
+	Preus(len(spineString), &ictus, &nexus)
+
+	if spineChars[ictus] != "a" {
+		t.Errorf("%q\n", spineString)
+	}
+
+	Preus(len(spineString), &ictus, &nexus)
+
+	if spineChars[ictus] != "r" {
+		t.Errorf("%q\n", spineString)
+	}
+
+	Preus(len(spineString), &ictus, &nexus)
+
+	if spineChars[ictus] != "c" {
+		t.Errorf("Rewind failed! %q\n", spineString)
+	}
+}
+
+// TestTSpine ::: SpineString characters are letters, not bytes.
+func TestTSpine(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Spine() :::\n")
+
+	tests := map[string][]string{
+		"Cra":        {"c", "r", "a"},
+		"Müller":     {"m", "ü", "l", "l", "e", "r"},
+		"Σαπφώ":      {"σ", "α", "π", "φ", "ώ"},
+		"Mu\u0308ll": {"m", "ü", "l", "l"}, // decomposed letters are composed
+	}
+	for z, want := range tests {
+		got := Spine(z)
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("Spine(%q) = %q, want %q", z, got, want)
+		}
+	}
+}
+
+// TestTGenerateUnicode ::: Non-ASCII text and spines match and line up.
+func TestTGenerateUnicode(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Generate() unicode :::\n")
+
+	tests := []struct {
+		spine  string
+		source string
+		want   string
+	}{
+		{
+			spine:  "Müller",
+			source: "ein mann\nüber brücken\nalle\nvoll\neine\nfrau\n",
+			want:   "ein Mann\n    Über brücken\n   aL\n  voLl\n    Eine\n   fRau\n     \n",
+		},
+		{
+			// the final sigma in the text matches the capital in the spine
+			spine:  "Σαπφώ",
+			source: "ερως\nκαι αγάπη\nπάντα\nφως\nκαι ώρα\n",
+			want:   " ερωΣ\n   κΑι αγά\n    Πάντα\n    Φως\nκαι Ώρα\n     \n",
+		},
+	}
+
+	for _, tt := range tests {
+		res, err := Generate(context.Background(), strings.NewReader(tt.source), tt.spine, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Text != tt.want {
+			t.Errorf("%s mesostic:\n%q\nwant:\n%q", tt.spine, res.Text, tt.want)
+		}
+	}
+
+	// the BOM at the start of a file is carried through without width
+	res, err := Generate(context.Background(), strings.NewReader("\ufeffthe quick\nbrown fox"), "qo", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "\ufeffthe Quick\n  brOwn fox\n" {
+		t.Errorf("BOM mesostic:\n%q", res.Text)
+	}
+}
+
+// TestTFoldDiacritics ::: Spine letters match accented characters, which keep their accents.
+func TestTFoldDiacritics(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.FoldDiacritics :::\n")
+
+	source := "près\nla forêt\nnoël\nkøl\n"
+
+	exact, err := Generate(context.Background(), strings.NewReader(source), "eee", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exact.Matched != 0 {
+		t.Errorf("exact matching found %d lines:\n%s", exact.Matched, exact.Text)
+	}
+
+	folded, err := Generate(context.Background(), strings.NewReader(source), "eeeo", Options{FoldDiacritics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "    prÈs\nla forÊt\n    noËl\n     kØl\n       \n"
+	if folded.Text != want {
+		t.Errorf("folded mesostic:\n%q\nwant:\n%q", folded.Text, want)
+	}
+
+	// a decomposed accent in the text still matches a composed spine letter exactly
+	res, err := Generate(context.Background(), strings.NewReader("pre\u0300s"), "è", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Matched != 1 {
+		t.Errorf("decomposed text did not match the composed spine: %q", res.Text)
+	}
+}
+
+// TestTmesoLine ::: The EastSide fragment of each algorithm mode for a known line.
+func TestTmesoLine(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target mesoLine() :::\n")
+
+	line := "xayaxbya"
+
+	tests := []struct {
+		name string
+		mode Algorithm
+		want string
+	}{
+		{"50%", Fifty, "xAyax"},
+		{"100%", Hundred, "xAy"},
+		{"acrostic", Acrostic, "xAyaxbya"},
+	}
+
+	for _, tt := range tests {
+		b, err := NewBuilder("ab", Options{Algorithm: tt.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !b.mesoLine(line, 1) {
+			t.Errorf("%s: SpineString character not found in %q", tt.name, line)
+		}
+		if b.spaces != 2 {
+			t.Errorf("%s: WestSide width %d, want 2", tt.name, b.spaces)
+		}
+		if got := b.Mesostic(); got != tt.want+"\n" {
+			t.Errorf("%s: fragment %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestTBuilder ::: Builders running at the same time do not share fragments.
+func TestTBuilder(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Builder :::\n")
+
+	source, err := os.ReadFile("../sources/lorenipsum-plaintext.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(source), "\n")
+
+	build := func(spine string) string {
+		b, err := NewBuilder(spine, Options{})
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		for i, l := range lines {
+			b.Line(l, i+1)
+		}
+		return b.Mesostic()
+	}
+
+	spines := []string{"craque", "lorem", "ipsum"}
+	want := make(map[string]string)
+	for _, spine := range spines {
+		want[spine] = build(spine)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		spine := spines[i%len(spines)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := build(spine); got != want[spine] {
+				t.Errorf("concurrent %q mesostic differs:\n%s\nwant:\n%s", spine, got, want[spine])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestTParseAlgorithm ::: Algorithm names map to mesoLine() modes and back.
+func TestTParseAlgorithm(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target ParseAlgorithm() :::\n")
+
+	for name, want := range map[string]Algorithm{
+		"":         Fifty,
+		"50":       Fifty,
+		"100":      Hundred,
+		"100%":     Hundred,
+		"acrostic": Acrostic,
+	} {
+		got, err := ParseAlgorithm(name)
+		if err != nil || got != want {
+			t.Errorf("ParseAlgorithm(%q) = %d, %v; want %d", name, got, err, want)
+		}
+		if again, _ := ParseAlgorithm(got.String()); again != got {
+			t.Errorf("ParseAlgorithm(%q) does not round trip", got)
+		}
+	}
+
+	if _, err := ParseAlgorithm("75"); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("ParseAlgorithm(\"75\") = %v, want ErrUnknownAlgorithm", err)
+	}
+}
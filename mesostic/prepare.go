@@ -0,0 +1,127 @@
+/*
+
+	Source Preprocessing
+
+	Texts typeset with hard line returns break sentences, and sometimes words, wherever the page ran out.
+	Preprocessing reads the source one line at a time before it is segmented,
+	so the Mesostic follows the language rather than the typesetting.
+
+*/
+
+package mesostic
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// hyphens ::: Runes that end a line in the middle of a word, the soft hyphen is only ever that.
+const hyphens = "-\u2010\u00ad"
+
+// preparer ::: An io.Reader of the source (src) with the preprocessing Options applied.
+type preparer struct {
+	src  *bufio.Reader
+	opts Options
+
+	out     bytes.Buffer // prepared text not read yet
+	pending string       // the last line, until the next one decides how they are joined
+	started bool         // a line has been read
+	done    bool         // the source is finished
+}
+
+// prepare ::: Wrap the source (r) in a preparer, when any preprocessing Options are set.
+func prepare(r io.Reader, opts Options) io.Reader {
+	if !opts.Unwrap && !opts.Dehyphenate && !opts.Normalize {
+		return r
+	}
+	return &preparer{src: bufio.NewReader(r), opts: opts}
+}
+
+// Read ::: Prepare lines until there is something to read.
+func (p *preparer) Read(buf []byte) (int, error) {
+	for p.out.Len() == 0 && !p.done {
+		if err := p.step(); err != nil {
+			return 0, err
+		}
+	}
+	if p.out.Len() == 0 {
+		return 0, io.EOF
+	}
+	return p.out.Read(buf)
+}
+
+// step ::: Read one line and join it to the pending line, or write the pending line out.
+func (p *preparer) step() error {
+	line, err := p.src.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	p.done = err == io.EOF
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+	if p.opts.Normalize {
+		line = norm.NFKC.String(strings.ReplaceAll(line, "\ufeff", ""))
+	}
+
+	if !p.started {
+		p.pending, p.started = line, true
+	} else {
+		p.join(line)
+	}
+
+	if p.done {
+		p.out.WriteString(p.pending)
+	}
+	return nil
+}
+
+// join ::: Add the next line (line) to the pending one, or write the pending line and hold this one.
+func (p *preparer) join(line string) {
+	blank := strings.TrimSpace(line) == ""
+
+	if p.opts.Dehyphenate && !blank && hyphenated(p.pending, line) {
+		_, size := utf8.DecodeLastRuneInString(p.pending)
+		word := p.pending[:len(p.pending)-size]
+
+		if p.opts.Unwrap {
+			p.pending = word + strings.TrimLeftFunc(line, unicode.IsSpace)
+			return
+		}
+
+		// the rest of the word moves up to this line
+		rest := strings.TrimLeftFunc(line, unicode.IsSpace)
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			p.pending = word + rest
+			return
+		}
+		p.out.WriteString(word + rest[:end] + "\n")
+		p.pending = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+		return
+	}
+
+	if p.opts.Unwrap && !blank && strings.TrimSpace(p.pending) != "" {
+		p.pending = strings.TrimRightFunc(p.pending, unicode.IsSpace) + " " + strings.TrimLeftFunc(line, unicode.IsSpace)
+		return
+	}
+
+	p.out.WriteString(p.pending + "\n")
+	p.pending = line
+}
+
+// hyphenated ::: The line (prev) ends with a word broken by a hyphen that continues in lowercase on (next).
+func hyphenated(prev, next string) bool {
+	h, size := utf8.DecodeLastRuneInString(prev)
+	if size == 0 || !strings.ContainsRune(hyphens, h) {
+		return false
+	}
+	before, _ := utf8.DecodeLastRuneInString(prev[:len(prev)-size])
+	after, _ := utf8.DecodeRuneInString(strings.TrimLeftFunc(next, unicode.IsSpace))
+	return unicode.IsLetter(before) && unicode.IsLower(after)
+}
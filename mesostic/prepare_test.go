@@ -0,0 +1,97 @@
+/*
+
+	Source Preprocessing Tests
+
+*/
+
+package mesostic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// TestTprepare ::: Each preprocessing option on its own and together.
+func TestTprepare(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target prepare() :::\n")
+
+	source := "\ufeffThe ﬁrst para-\r\ngraph is hard\r\nwrapped.\r\n\r\nA sec-\r\nond one, with a Well-\r\nKnown name.\r\n"
+
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, source},
+		{Options{Normalize: true}, "The first para-\ngraph is hard\nwrapped.\n\nA sec-\nond one, with a Well-\nKnown name.\n"},
+		{Options{Dehyphenate: true}, "\ufeffThe ﬁrst paragraph\nis hard\nwrapped.\n\nA second\none, with a Well-\nKnown name.\n"},
+		{Options{Unwrap: true}, "\ufeffThe ﬁrst para- graph is hard wrapped.\n\nA sec- ond one, with a Well- Known name.\n"},
+		{Options{Unwrap: true, Dehyphenate: true, Normalize: true}, "The first paragraph is hard wrapped.\n\nA second one, with a Well- Known name.\n"},
+	}
+	for _, tt := range tests {
+		got, err := io.ReadAll(prepare(iotest.OneByteReader(strings.NewReader(source)), tt.opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%+v:\n%q\nwant:\n%q", tt.opts, got, tt.want)
+		}
+	}
+
+	// a word broken on the last line is still joined
+	got, _ := io.ReadAll(prepare(strings.NewReader("the end-\nless"), Options{Dehyphenate: true}))
+	if string(got) != "the endless" {
+		t.Errorf("last line %q", got)
+	}
+}
+
+// TestTStreamUnwrap ::: A hard-wrapped source has one Mesostic line per paragraph once it is unwrapped.
+func TestTStreamUnwrap(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.Unwrap :::\n")
+
+	raw, err := os.ReadFile("../sources/u2k.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every run of lines without a blank one is a paragraph
+	var paragraphs, blanks int
+	blank := true
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.TrimSpace(line) != "" && blank {
+			paragraphs++
+		}
+		blank = strings.TrimSpace(line) == ""
+		if blank {
+			blanks++
+		}
+	}
+
+	res, err := Generate(context.Background(), strings.NewReader(string(raw)), "stately", Options{Unwrap: true, Normalize: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines int
+	for _, line := range strings.Split(res.Text, "\n") {
+		if strings.ContainsRune(line, '\r') {
+			t.Fatalf("carriage return in %q", line)
+		}
+		if strings.TrimSpace(line) != "" {
+			lines++
+		}
+	}
+	if res.Matched != lines || lines > paragraphs {
+		t.Errorf("%d lines, %d matched, from %d paragraphs", lines, res.Matched, paragraphs)
+	}
+	if res.Source != paragraphs+blanks {
+		t.Errorf("%d segments, want %d paragraphs and %d blank lines", res.Source, paragraphs, blanks)
+	}
+	if res.Lines[0].Byte != 0 || res.Lines[0].Spine != "S" {
+		t.Errorf("first line %+v", res.Lines[0])
+	}
+}
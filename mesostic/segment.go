@@ -0,0 +1,303 @@
+/*
+
+	Source Segmentation
+
+	The engine places one SpineString character in each segment of the source.
+	Segments are the lines of the source unless another Segmentation is chosen,
+	then line returns inside a segment are only whitespace and every run of whitespace becomes one space.
+
+*/
+
+package mesostic
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrBadSegment ::: The segmentation or its width is out of range.
+var ErrBadSegment = errors.New("mesostic: segmentation out of range")
+
+// maxSegment ::: The longest segment in bytes, a longer sentence or paragraph is split at its last whitespace.
+const maxSegment = 1 << 20
+
+// Segmentation ::: How the source is split into the segments that become Mesostic lines.
+type Segmentation int
+
+// Segmentations, the zero value of Options splits on line returns.
+const (
+	Lines      Segmentation = iota // Line returns, as written
+	Sentences                      // Ends with . ! or ?, but not after abbreviations like "Dr."
+	Clauses                        // Sentences, also split after , ; : and dashes
+	Width                          // Wrapped at Options.SegmentWidth characters, between words when possible
+	Paragraphs                     // Separated by blank lines
+)
+
+// ParseSegmentation ::: Convert a segmentation name into a Segmentation, an empty name is Lines.
+func ParseSegmentation(s string) (Segmentation, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "line", "lines":
+		return Lines, nil
+	case "sentence", "sentences":
+		return Sentences, nil
+	case "clause", "clauses", "punctuation":
+		return Clauses, nil
+	case "width", "fixed":
+		return Width, nil
+	case "paragraph", "paragraphs":
+		return Paragraphs, nil
+	}
+	return 0, fmt.Errorf("%w: unknown segmentation %q", ErrBadSegment, s)
+}
+
+// String ::: The segmentation name accepted by ParseSegmentation().
+func (g Segmentation) String() string {
+	switch g {
+	case Lines:
+		return "lines"
+	case Sentences:
+		return "sentences"
+	case Clauses:
+		return "clauses"
+	case Width:
+		return "width"
+	case Paragraphs:
+		return "paragraphs"
+	}
+	return fmt.Sprintf("Segmentation(%d)", int(g))
+}
+
+// MarshalText ::: Segmentations are written by name, e.g. in JSON.
+func (g Segmentation) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+// UnmarshalText ::: Segmentations are read by name, e.g. from JSON.
+func (g *Segmentation) UnmarshalText(text []byte) error {
+	seg, err := ParseSegmentation(string(text))
+	if err != nil {
+		return err
+	}
+	*g = seg
+	return nil
+}
+
+// segmenter ::: Returns the next segment of (r) each time it is called, false once the source is done.
+func segmenter(r io.Reader, opts Options) func() (string, bool, error) {
+	if opts.Segment == Lines {
+		/*
+			Every line return ends a line, and whatever follows the last one is a line too,
+			even when it is empty. This matches splitting the whole source on "\n".
+		*/
+		source := bufio.NewReader(r)
+		done := false
+		return func() (string, bool, error) {
+			if done {
+				return "", false, nil
+			}
+			sline, err := source.ReadString('\n')
+			if err == io.EOF {
+				done, err = true, nil
+			}
+			if err != nil {
+				return "", false, err
+			}
+			return strings.TrimSuffix(sline, "\n"), true, nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxSegment)
+	switch opts.Segment {
+	case Sentences:
+		scanner.Split(scanLimit(scanEnds(sentenceEnd)))
+	case Clauses:
+		scanner.Split(scanLimit(scanEnds(clauseEnd)))
+	case Width:
+		scanner.Split(scanLimit(scanWidth(opts.SegmentWidth)))
+	case Paragraphs:
+		scanner.Split(scanLimit(scanEnds(nil)))
+	}
+	return func() (string, bool, error) {
+		if !scanner.Scan() {
+			return "", false, scanner.Err()
+		}
+		return strings.Join(strings.Fields(scanner.Text()), " "), true, nil
+	}
+}
+
+// sentenceEnd ::: Runes that end a sentence.
+func sentenceEnd(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+// clauseEnd ::: Runes that end a clause.
+func clauseEnd(r rune) bool {
+	return sentenceEnd(r) || r == ',' || r == ';' || r == ':' || r == '—' || r == '–'
+}
+
+// closer ::: Runes that stay with the end of a sentence, "He said "no." Then..." ends after the quote.
+func closer(r rune) bool {
+	return strings.ContainsRune(`"')]}’”»`, r)
+}
+
+// abbreviations ::: Words ending in a period that do not end a sentence.
+var abbreviations = map[string]bool{
+	"mr.": true, "mrs.": true, "ms.": true, "dr.": true, "prof.": true, "rev.": true,
+	"st.": true, "mt.": true, "jr.": true, "sr.": true, "gen.": true, "gov.": true,
+	"vs.": true, "no.": true, "fig.": true, "approx.": true, "ca.": true, "cf.": true,
+}
+
+// abbreviation ::: The text (s) ends with an abbreviation, an initial like "J.", or a dotted one like "e.g."
+func abbreviation(s []byte) bool {
+	word := s[bytes.LastIndexFunc(s, unicode.IsSpace)+1:]
+	word = bytes.TrimLeftFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+	if abbreviations[strings.ToLower(string(word))] {
+		return true
+	}
+
+	// every part between the periods is a single letter
+	for _, part := range bytes.Split(bytes.TrimSuffix(word, []byte(".")), []byte(".")) {
+		if utf8.RuneCount(part) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// skipSpace ::: The byte offset of the first rune in (data) that is not whitespace.
+func skipSpace(data []byte) int {
+	i := 0
+	for i < len(data) {
+		r, w := utf8.DecodeRune(data[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += w
+	}
+	return i
+}
+
+// scanLimit ::: Wraps (split) so a segment that fills the scanner buffer is cut instead of failing with bufio.ErrTooLong.
+// The cut is at the last whitespace, or before the last rune when there is none.
+func scanLimit(split bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if err != nil || token != nil || advance > 0 || atEOF || len(data) < maxSegment {
+			return advance, token, err
+		}
+
+		if i := bytes.LastIndexFunc(data, unicode.IsSpace); i > 0 {
+			_, w := utf8.DecodeRune(data[i:])
+			return i + w, data[:i], nil
+		}
+		i := len(data) - 1
+		for i > 0 && !utf8.RuneStart(data[i]) {
+			i--
+		}
+		return i, data[:i], nil
+	}
+}
+
+// scanEnds ::: A bufio.SplitFunc for segments that end with a rune matching (end) followed by whitespace.
+// A blank line always ends a segment, so a nil (end) splits paragraphs.
+func scanEnds(end func(rune) bool) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		start := skipSpace(data)
+
+		for i := start; i < len(data); {
+			if !atEOF && !utf8.FullRune(data[i:]) {
+				break
+			}
+			r, w := utf8.DecodeRune(data[i:])
+
+			switch {
+			case r == '\n':
+				// a blank line, whitespace up to the next line return
+				j := i + w
+				for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\r') {
+					j++
+				}
+				if j == len(data) && !atEOF {
+					return start, nil, nil // more data decides
+				}
+				if j < len(data) && data[j] == '\n' {
+					return j + 1, data[start:i], nil
+				}
+			case end != nil && end(r):
+				j := i + w
+				for j < len(data) {
+					c, cw := utf8.DecodeRune(data[j:])
+					if !closer(c) {
+						break
+					}
+					j += cw
+				}
+				if j == len(data) {
+					if atEOF {
+						return j, data[start:j], nil
+					}
+					return start, nil, nil // more data decides
+				}
+				if next, _ := utf8.DecodeRune(data[j:]); unicode.IsSpace(next) && (r != '.' || !abbreviation(data[start:i+w])) {
+					return j, data[start:j], nil
+				}
+			}
+			i += w
+		}
+
+		if atEOF && start < len(data) {
+			return len(data), data[start:], nil
+		}
+		return start, nil, nil
+	}
+}
+
+// scanWidth ::: A bufio.SplitFunc for segments of at most (n) characters, split between words when possible.
+func scanWidth(n int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		start := skipSpace(data)
+
+		var chars int // printed characters, a run of whitespace is one space
+		gap := -1     // the last whitespace after a word
+		space := false
+		for i := start; i < len(data); {
+			if !atEOF && !utf8.FullRune(data[i:]) {
+				break
+			}
+			r, w := utf8.DecodeRune(data[i:])
+
+			switch {
+			case unicode.IsSpace(r):
+				if chars == n {
+					return i + w, data[start:i], nil
+				}
+				if !space {
+					gap, space = i, true
+					chars++
+				}
+			case !isMark(r):
+				if chars == n {
+					if gap < 0 {
+						return i, data[start:i], nil // a word longer than the width is split
+					}
+					return gap + 1, data[start:gap], nil
+				}
+				chars++
+				space = false
+			}
+			i += w
+		}
+
+		if atEOF && start < len(data) {
+			return len(data), data[start:], nil
+		}
+		return start, nil, nil
+	}
+}
@@ -0,0 +1,186 @@
+/*
+
+	Source Segmentation Tests
+
+*/
+
+package mesostic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// segments ::: Every segment of (source), read one byte at a time so the split functions have to ask for more.
+func segments(t *testing.T, source string, opts Options) []string {
+	t.Helper()
+
+	var got []string
+	next := segmenter(iotest.OneByteReader(strings.NewReader(source)), opts)
+	for {
+		seg, more, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !more {
+			return got
+		}
+		got = append(got, seg)
+	}
+}
+
+// TestTsegmenter ::: Each Segmentation splits the same source its own way.
+func TestTsegmenter(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target segmenter() :::\n")
+
+	source := "Dr. Smith met J. Cage in the U.S. today, and they talked.\n" +
+		"Then \"silence!\" he said;\nthe rest\nis  music.\n\n" +
+		"A second paragraph... with an ending?"
+
+	tests := []struct {
+		opts Options
+		want []string
+	}{
+		{Options{}, []string{
+			"Dr. Smith met J. Cage in the U.S. today, and they talked.",
+			"Then \"silence!\" he said;",
+			"the rest",
+			"is  music.",
+			"",
+			"A second paragraph... with an ending?",
+		}},
+		{Options{Segment: Sentences}, []string{
+			"Dr. Smith met J. Cage in the U.S. today, and they talked.",
+			"Then \"silence!\"",
+			"he said; the rest is music.",
+			"A second paragraph...",
+			"with an ending?",
+		}},
+		{Options{Segment: Clauses}, []string{
+			"Dr. Smith met J. Cage in the U.S. today,",
+			"and they talked.",
+			"Then \"silence!\"",
+			"he said;",
+			"the rest is music.",
+			"A second paragraph...",
+			"with an ending?",
+		}},
+		{Options{Segment: Width, SegmentWidth: 20}, []string{
+			"Dr. Smith met J.",
+			"Cage in the U.S.",
+			"today, and they",
+			"talked. Then",
+			"\"silence!\" he said;",
+			"the rest is music. A",
+			"second paragraph...",
+			"with an ending?",
+		}},
+		{Options{Segment: Paragraphs}, []string{
+			"Dr. Smith met J. Cage in the U.S. today, and they talked. Then \"silence!\" he said; the rest is music.",
+			"A second paragraph... with an ending?",
+		}},
+	}
+	for _, tt := range tests {
+		got := segments(t, source, tt.opts)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s:\n%q\nwant:\n%q", tt.opts.Segment, got, tt.want)
+		}
+	}
+
+	// words longer than the width are split
+	long := segments(t, "mesostic poetry", Options{Segment: Width, SegmentWidth: 4})
+	if !slices.Equal(long, []string{"meso", "stic", "poet", "ry"}) {
+		t.Errorf("long words %q", long)
+	}
+
+	// a segment longer than maxSegment is cut, between words when there are any
+	for _, word := range []string{"silence ", "silence"} {
+		source := strings.Repeat(word, maxSegment/len(word)+1000)
+		for _, opts := range []Options{{Segment: Sentences}, {Segment: Clauses}, {Segment: Paragraphs}} {
+			var got []string
+			next := segmenter(strings.NewReader(source), opts)
+			for {
+				seg, more, err := next()
+				if err != nil {
+					t.Fatalf("%s %q: %v", opts.Segment, word, err)
+				}
+				if !more {
+					break
+				}
+				got = append(got, seg)
+			}
+			sep := ""
+			if word != "silence" {
+				sep = " "
+			}
+			if len(got) != 2 || strings.Join(got, sep) != strings.TrimSpace(source) {
+				t.Errorf("%s %q: %d segments", opts.Segment, word, len(got))
+			}
+		}
+	}
+}
+
+// TestTGenerateSegments ::: The Mesostic follows the segments and the Result records how the source was split.
+func TestTGenerateSegments(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.Segment :::\n")
+
+	res, err := Generate(context.Background(), strings.NewReader("the quick brown fox. jumps over\nthe lazy dog."), "cra", Options{Segment: Sentences})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Segment != Sentences || res.Source != 2 || res.Matched != 2 {
+		t.Errorf("segment %s, %d source, %d matched", res.Segment, res.Source, res.Matched)
+	}
+	if res.Text != "  the quiCk b\njumps oveR the l\n" {
+		t.Errorf("mesostic %q", res.Text)
+	}
+
+	// offsets are in the segment, with its whitespace collapsed, not in the source
+	source := "the  quick\nbrown  fox. jumps  over\nthe lazy dog."
+	for _, opts := range []Options{{Segment: Sentences}, {Procedure: WritingThrough, Segment: Clauses}} {
+		res, err := Generate(context.Background(), strings.NewReader(source), "cra", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segs := segments(t, source, opts)
+		for _, lf := range res.Lines {
+			seg := segs[lf.Index-1]
+			if lf.Miss || !strings.HasPrefix(strings.ToUpper(seg[lf.Byte:]), lf.Spine) || strings.ToUpper(string([]rune(seg)[lf.Rune])) != lf.Spine {
+				t.Errorf("%s %s: %+v is not at its offsets in %q", opts.Procedure, opts.Segment, lf, seg)
+			}
+		}
+		if lf := res.Lines[0]; lf.Byte != 7 || source[lf.Byte] == 'c' {
+			t.Errorf("%s %s: offset %d, the same as in the source", opts.Procedure, opts.Segment, lf.Byte)
+		}
+	}
+
+	for _, opts := range []Options{{Segment: Width}, {Segment: Paragraphs + 1}, {SegmentWidth: -1}} {
+		if _, err := Generate(context.Background(), strings.NewReader("text"), "cra", opts); !errors.Is(err, ErrBadSegment) {
+			t.Errorf("%+v: %v", opts, err)
+		}
+	}
+}
+
+// TestTParseSegmentation ::: Segmentation names round trip, unknown names fail.
+func TestTParseSegmentation(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target ParseSegmentation() :::\n")
+
+	for _, g := range []Segmentation{Lines, Sentences, Clauses, Width, Paragraphs} {
+		got, err := ParseSegmentation(g.String())
+		if err != nil || got != g {
+			t.Errorf("%s: %s, %v", g, got, err)
+		}
+	}
+	if got, _ := ParseSegmentation(""); got != Lines {
+		t.Errorf("empty name is %s", got)
+	}
+	if _, err := ParseSegmentation("stanzas"); !errors.Is(err, ErrBadSegment) {
+		t.Errorf("unknown name: %v", err)
+	}
+}
@@ -0,0 +1,392 @@
+/*
+
+	Writing-Through
+
+	Cage wrote through a source word by word rather than line by line,
+	the next word holding the SpineString character is found wherever it is in the text.
+	Each SpineString character makes one Mesostic line, and the words between two of them
+	are shared out as the East wing of the first and the West wing of the second.
+	Each half is kept to maxWing words, or the wing widths in Words when they are wider,
+	so the walk never holds more than that between two SpineString words.
+	The 50% and 100% rules hold as they do line by line, the East wing stops before the next
+	SpineString character, and at 100% before its own character appears again.
+
+	Jackson Mac Low's diastic walks the words the same way, but the nth SpineString character
+	has to be the nth letter of the word, and each line is only the word.
+
+*/
+
+package mesostic
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Procedure ::: How the source is walked for the SpineString characters.
+type Procedure int
+
+// Procedures, the zero value of Options searches each line.
+const (
+	LineByLine     Procedure = iota // Each segment is searched for the SpineString character, a miss is a blank line
+	WritingThrough                  // Word by word across segments, one line for each SpineString character
+	Diastic                         // Word by word, the nth SpineString character is the nth letter of the word
+)
+
+// ParseProcedure ::: Convert a procedure name into a Procedure, an empty name is LineByLine.
+func ParseProcedure(p string) (Procedure, error) {
+	switch strings.ToLower(strings.TrimSpace(p)) {
+	case "", "lines", "line-by-line":
+		return LineByLine, nil
+	case "words", "writing-through":
+		return WritingThrough, nil
+	case "diastic":
+		return Diastic, nil
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownProcedure, p)
+}
+
+// String ::: The procedure name accepted by ParseProcedure().
+func (p Procedure) String() string {
+	switch p {
+	case LineByLine:
+		return "lines"
+	case WritingThrough:
+		return "writing-through"
+	case Diastic:
+		return "diastic"
+	}
+	return fmt.Sprintf("Procedure(%d)", int(p))
+}
+
+// MarshalText ::: Procedures are written by name, e.g. in JSON.
+func (p Procedure) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText ::: Procedures are read by name, e.g. from JSON.
+func (p *Procedure) UnmarshalText(text []byte) error {
+	proc, err := ParseProcedure(string(text))
+	if err != nil {
+		return err
+	}
+	*p = proc
+	return nil
+}
+
+// maxWing ::: The most words kept for each wing between two SpineString words.
+const maxWing = 20
+
+// wordHit ::: A word holding a SpineString character, waiting for the words after it.
+type wordHit struct {
+	frag LineFrag // Index, Byte, Rune, Spine, Stanza, and Break are known
+	key  string   // the SpineString character
+	west []string // the West wing words
+	head []string // characters of the word before the SpineString character
+	tail []string // characters of the word after the SpineString character
+}
+
+// word ::: A whitespace separated word and where it starts in its segment.
+type word struct {
+	text string
+	byte int
+	rune int
+}
+
+// words ::: Split (s) into words with their offsets.
+func words(s string) []word {
+	var ws []word
+
+	start, runes, startRune := -1, 0, 0
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			ws = append(ws, word{s[start:i], start, startRune})
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start, startRune = i, runes
+		}
+		runes++
+	}
+	if start >= 0 {
+		ws = append(ws, word{s[start:], start, startRune})
+	}
+	return ws
+}
+
+// spot ::: The position of the current SpineString character among the characters (chars) of a word, or -1.
+//
+//	50% ::: the first one in the word, it cannot appear in the words passed over before it
+//	100% ::: also the only one in the word, and neither wing holds it, see stop()
+//	Diastic ::: the letter of the word at the position of the character in the SpineString
+func (b *Builder) spot(chars []string) int {
+	z := b.spine[b.ictus]
+
+	if b.proc == Diastic {
+		n := 0 // letters, punctuation does not count
+		for i, c := range chars {
+			if r, _ := utf8.DecodeRuneInString(c); !unicode.IsLetter(r) {
+				continue
+			}
+			if n == b.ictus {
+				if b.key(c) == z {
+					return i
+				}
+				return -1
+			}
+			n++
+		}
+		return -1
+	}
+
+	pos := -1
+	for i, c := range chars {
+		if b.key(c) != z {
+			continue
+		}
+		if pos >= 0 {
+			return -1 // a second one
+		}
+		pos = i
+		if b.mode != Hundred {
+			break
+		}
+	}
+	return pos
+}
+
+// key ::: The form of the character (c) compared with the SpineString characters.
+func (b *Builder) key(c string) string {
+	key := fold(c)
+	if b.bare {
+		key = bare(key)
+	}
+	return key
+}
+
+// WriteThrough ::: Walk one segment (s) with number (c) word by word, for the WritingThrough and Diastic Procedures.
+// The line for a SpineString word is finished when the next one is found, or by Flush().
+// Returns whether any SpineString character was found in the segment.
+func (b *Builder) WriteThrough(s string, c int) bool {
+	var found bool
+
+	for _, w := range words(s) {
+		if b.Done() {
+			break
+		}
+
+		chars := graphemes(w.text)
+		pos := b.spot(chars)
+		if pos < 0 {
+			b.pass(w.text)
+			continue
+		}
+		found = true
+		b.matched++
+		b.misses, b.missBytes = 0, 0
+
+		// the words since the last hit are shared between it and this one
+		west := b.finish(false)
+		if b.mode == Hundred {
+			// a word passed over for holding it twice cannot come before it
+			for i := len(west) - 1; i >= 0; i-- {
+				if b.holds(west[i], b.spine[b.ictus]) {
+					west = west[i+1:]
+					break
+				}
+			}
+		}
+
+		prefix := strings.Join(chars[:pos], "")
+		hit := &wordHit{
+			frag: LineFrag{
+				Index:  c,
+				Spine:  strings.ToUpper(chars[pos]),
+				Byte:   w.byte + len(prefix),
+				Rune:   w.rune + utf8.RuneCountInString(prefix),
+				Stanza: b.cycles + 1,
+			},
+			key:  b.spine[b.ictus],
+			west: west,
+			head: lower(chars[:pos]),
+			tail: lower(chars[pos+1:]),
+		}
+		b.hit = hit
+
+		last := b.ictus == len(b.spine)-1
+		Ictus(len(b.spine), &b.ictus, &b.nexus)
+		if last {
+			b.cycles++
+			hit.frag.Break = b.stanzas
+		}
+	}
+
+	if !found {
+		b.missed++
+		b.misses++
+		b.missBytes += int64(len(s)) + 1 // with its line return
+		if b.tolerance() {
+			b.skipped = append(b.skipped, Skip{Char: b.spine[b.ictus], Index: b.ictus, Line: c})
+			b.misses, b.missBytes = 0, 0
+
+			last := b.ictus == len(b.spine)-1
+			Ictus(len(b.spine), &b.ictus, &b.nexus)
+			if last {
+				b.cycles++
+				if b.hit != nil {
+					b.hit.frag.Break = b.stanzas
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// Flush ::: Finish the line for the last SpineString word with the words after it.
+func (b *Builder) Flush() {
+	b.finish(true)
+	b.gap = nil
+}
+
+// pass ::: Keep a word (w) without the SpineString character for the wings, the middle of a long gap is dropped.
+func (b *Builder) pass(w string) {
+	if b.proc == Diastic {
+		return // the lines are only the words
+	}
+	b.gap = append(b.gap, w)
+	if limit := b.wingLimit(); len(b.gap) > 2*limit {
+		b.gap = append(b.gap[:limit], b.gap[limit+1:]...)
+	}
+}
+
+// wingLimit ::: The most words kept for a wing.
+func (b *Builder) wingLimit() int {
+	if b.wingUnit == Words {
+		return max(maxWing, b.west, b.east)
+	}
+	return maxWing
+}
+
+// finish ::: Add the line for the waiting SpineString word to the fragments.
+// The words since then are shared with the next West wing, which gets the rest of them,
+// unless this is the last line (flush) and they all go East.
+func (b *Builder) finish(flush bool) []string {
+	gap := b.gap
+	b.gap = nil
+
+	hit := b.hit
+	b.hit = nil
+	if hit == nil {
+		return gap
+	}
+
+	// how many words go East
+	e := len(gap)
+	if !flush {
+		switch {
+		case b.wingUnit == Words && b.east > 0:
+			e = min(b.east, len(gap))
+		case b.wingUnit == Words && b.west > 0:
+			e = len(gap) - min(b.west, len(gap))
+		default:
+			e = len(gap) / 2
+		}
+	}
+	e = min(e, b.wingLimit()) // the words kept from the start of the gap
+
+	// the East wing stops before the next SpineString character, the words after it go West
+	estack := hit.tail
+	if cut := b.stop(hit.tail, hit.key); cut < len(hit.tail) {
+		estack, e = hit.tail[:cut], 0
+	} else {
+		for i, w := range gap[:e] {
+			if chars := graphemes(w); b.stop(chars, hit.key) < len(chars) {
+				e = i
+				break
+			}
+		}
+		if e > 0 {
+			estack = append(append(estack, " "), wingChars(gap[:e])...)
+		}
+	}
+
+	wstack := wingChars(hit.west)
+	if len(wstack) > 0 {
+		wstack = append(wstack, " ")
+	}
+	wstack = append(wstack, hit.head...)
+
+	west := westWing(wstack, b.west, b.wingUnit)
+	east := eastWing(estack, b.east, b.wingUnit)
+	if b.boundaries {
+		west = westBoundary(wstack, west)
+		east = eastBoundary(estack, east)
+	}
+
+	frag := hit.frag
+	frag.LineNum = len(b.frags) + 1
+	frag.West = strings.Join(west, "")
+	frag.East = strings.Join(east, "")
+	frag.WChars = width(frag.West + frag.Spine)
+	frag.Data = frag.West + frag.Spine + frag.East
+	b.frags = append(b.frags, frag)
+
+	if frag.WChars > b.spaces {
+		b.spaces = frag.WChars
+	}
+
+	return gap[e:]
+}
+
+// stop ::: Where the East wing (chars) of the SpineString word for (key) ends, or len(chars).
+//
+//	50% ::: before the next SpineString character
+//	100% ::: before the next one, or its own coming back
+//
+// The Meso-Acrostic and Diastic keep the whole of it.
+func (b *Builder) stop(chars []string, key string) int {
+	if b.proc != WritingThrough || b.mode == Acrostic {
+		return len(chars)
+	}
+	next := b.spine[b.ictus]
+	for i, c := range chars {
+		if k := b.key(c); k == next || (b.mode == Hundred && k == key) {
+			return i
+		}
+	}
+	return len(chars)
+}
+
+// wingChars ::: The lowercase characters of the wing words (ws), separated by spaces.
+func wingChars(ws []string) []string {
+	var chars []string
+	for i, w := range ws {
+		if i > 0 {
+			chars = append(chars, " ")
+		}
+		chars = append(chars, lower(graphemes(w))...)
+	}
+	return chars
+}
+
+// lower ::: Lowercase copies of the characters (chars).
+func lower(chars []string) []string {
+	low := make([]string, len(chars))
+	for i, c := range chars {
+		low[i] = strings.ToLower(c)
+	}
+	return low
+}
+
+// holds ::: The word (w) has a character matching the SpineString character (z).
+func (b *Builder) holds(w, z string) bool {
+	for _, c := range graphemes(w) {
+		if b.key(c) == z {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+/*
+
+	Writing-Through Tests
+
+*/
+
+package mesostic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// TestTWritingThrough ::: One line for each SpineString character, found word by word across the lines.
+func TestTWritingThrough(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.Procedure WritingThrough :::\n")
+
+	res, err := Generate(context.Background(), strings.NewReader("the quick brown fox\njumps over the lazy dog"), "fox",
+		Options{Procedure: WritingThrough})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "ox" is cut at the o of the next line, the x is never found so the last line keeps the rest of the text
+	want := LineFrags{
+		{Index: 1, LineNum: 1, WChars: 17, Data: "the quick brown F", West: "the quick brown ", Spine: "F", Byte: 16, Rune: 16, Stanza: 1},
+		{Index: 2, LineNum: 2, WChars: 7, Data: "jumps Over the lazy dog", West: "jumps ", Spine: "O", East: "ver the lazy dog", Byte: 6, Rune: 6, Stanza: 1},
+	}
+	if len(res.Lines) != len(want) {
+		t.Fatalf("%d lines, want %d:\n%+v", len(res.Lines), len(want), res.Lines)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(res.Lines[i], want[i]) {
+			t.Errorf("line %d:\n%+v\nwant:\n%+v", i+1, res.Lines[i], want[i])
+		}
+	}
+	if res.Procedure != WritingThrough || res.Matched != 2 || res.Source != 2 || res.Missed != 0 {
+		t.Errorf("procedure %s, %d matched, %d missed, %d source", res.Procedure, res.Matched, res.Missed, res.Source)
+	}
+
+	tests := []struct {
+		source string
+		spine  string
+		opts   Options
+		want   string
+	}{
+		// the words between two SpineString words are shared
+		{"one two three four five six", "ef", Options{Procedure: WritingThrough}, "    onE two\nthree Four\n   fivE six\n"},
+		// the East wing stops before the next SpineString character, even in the SpineString word
+		{"the cat sat on a mat", "cat", Options{Procedure: WritingThrough}, "   the C\n      sA\non a maT\n"},
+		{"cab\ncab\ncab", "cab", Options{Procedure: WritingThrough}, "  C\n cA\ncaB\n"},
+		{"hello lol lip", "l", Options{Procedure: WritingThrough}, "heL\n  Lo\n  Lip\n"},
+		// 100% takes the only one in a word, and neither wing holds it
+		{"hello world one eel", "oe", Options{Procedure: WritingThrough, Algorithm: Hundred}, "    hellO\nworld onE\n"},
+		{"hello lol lip", "l", Options{Procedure: WritingThrough, Algorithm: Hundred}, "Lip\n"},
+		// the Meso-Acrostic has no rule
+		{"the cat sat on a mat", "cat", Options{Procedure: WritingThrough, Algorithm: Acrostic}, "the Cat\n   sAt on\na maT\n"},
+		// wing widths in words
+		{"a b c d E f g h i j", "ei", Options{Procedure: WritingThrough, WestWidth: 1, EastWidth: 2, WingUnit: Words}, "d E f g\nh I j\n"},
+	}
+	for _, tt := range tests {
+		res, err := Generate(context.Background(), strings.NewReader(tt.source), tt.spine, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Text != tt.want {
+			t.Errorf("%q with %q:\n%q\nwant:\n%q", tt.source, tt.spine, res.Text, tt.want)
+		}
+		if tt.opts.Algorithm != Acrostic {
+			checkRule(t, res)
+		}
+	}
+
+	if _, err := Generate(context.Background(), strings.NewReader("text"), "cra", Options{Procedure: WritingThrough + 5}); !errors.Is(err, ErrUnknownProcedure) {
+		t.Errorf("unknown procedure: %v", err)
+	}
+}
+
+// TestTWritingThroughStream ::: A large source is walked in cycles, with stanzas and a limit.
+func TestTWritingThroughStream(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Stream() WritingThrough :::\n")
+
+	source, err := os.Open("../sources/u2k.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	var mesostic strings.Builder
+	res, err := Stream(context.Background(), &mesostic, source, "joyce", Options{Procedure: WritingThrough, Stanzas: true, MaxCycles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Cycles != 3 || res.Matched != 15 || len(res.Lines) != 15 {
+		t.Errorf("%d cycles, %d matched, %d lines", res.Cycles, res.Matched, len(res.Lines))
+	}
+	if stanzas := strings.Count(mesostic.String(), "\n\n"); stanzas != 2 {
+		t.Errorf("%d stanza breaks, want 2", stanzas)
+	}
+	for i, lf := range res.Lines {
+		if lf.Stanza != i/5+1 || lf.Spine != strings.ToUpper(string("joyce"[i%5])) {
+			t.Errorf("line %d %+v", i+1, lf)
+		}
+	}
+	checkRule(t, res)
+
+	for _, mode := range []Algorithm{Fifty, Hundred} {
+		if _, err := source.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		res, err := Generate(context.Background(), source, "ulysses", Options{Algorithm: mode, Procedure: WritingThrough, MaxCycles: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRule(t, res)
+	}
+}
+
+// TestTDiastic ::: The nth SpineString character is the nth letter of the word, each line is only the word.
+func TestTDiastic(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Options.Procedure Diastic :::\n")
+
+	tests := []struct {
+		source string
+		spine  string
+		want   string
+	}{
+		{"a cat sat\non the mat with bats", "cat", "  Cat\n sAt\nmaT\n"},
+		{"“apple,” she said", "a", "“Apple,”\n"}, // punctuation is not a letter
+		{"catch the cat", "cac", " Catch\ncAt\n"},
+	}
+	for _, tt := range tests {
+		res, err := Generate(context.Background(), strings.NewReader(tt.source), tt.spine, Options{Procedure: Diastic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Text != tt.want {
+			t.Errorf("%q with %q:\n%q\nwant:\n%q", tt.source, tt.spine, res.Text, tt.want)
+		}
+	}
+
+	source, err := os.Open("../sources/u2k.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	res, err := Stream(context.Background(), io.Discard, source, "joyce", Options{Procedure: Diastic, MaxCycles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, lf := range res.Lines {
+		n := i % 5
+		if letters := []rune(strings.TrimLeftFunc(lf.Data, func(r rune) bool { return !unicode.IsLetter(r) })); len(letters) <= n || string(letters[n]) != lf.Spine {
+			t.Errorf("line %d %q does not have %s at %d", i+1, lf.Data, lf.Spine, n+1)
+		}
+	}
+	if res.Cycles != 2 || len(res.Lines) != 10 {
+		t.Errorf("%d cycles, %d lines", res.Cycles, len(res.Lines))
+	}
+}
+
+// TestTParseProcedure ::: Procedure names round trip, unknown names fail.
+func TestTParseProcedure(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target ParseProcedure() :::\n")
+
+	for _, p := range []Procedure{LineByLine, WritingThrough, Diastic} {
+		got, err := ParseProcedure(p.String())
+		if err != nil || got != p {
+			t.Errorf("%s: %s, %v", p, got, err)
+		}
+	}
+	if _, err := ParseProcedure("sideways"); !errors.Is(err, ErrUnknownProcedure) {
+		t.Errorf("unknown name: %v", err)
+	}
+}
@@ -44,18 +44,30 @@ var hpschdFsubTimer = prometheus.NewHistogram(prometheus.HistogramOpts{
 	Buckets: prometheus.LinearBuckets(0.001, 0.01, 50),
 })
 
-var hpschdMesolineTimer = prometheus.NewHistogram(prometheus.HistogramOpts{
-	Name:    "hpschdMesolineTimer",
-	Help:    "Historgram for the runtime of mesoLine.",
-	Buckets: prometheus.LinearBuckets(0.001, 0.01, 50),
-})
-
 var hpschdNASAetlTimer = prometheus.NewHistogram(prometheus.HistogramOpts{
 	Name:    "hpschdNASAetlTimer",
 	Help:    "Historgram for the runtime of NASAetl.",
 	Buckets: prometheus.LinearBuckets(0.001, 0.01, 50),
 })
 
+var hpschdLiveTimer = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "hpschdLiveTimer",
+	Help:    "Historgram for the runtime of live generations (WebSocket).",
+	Buckets: prometheus.LinearBuckets(0.001, 0.01, 50),
+})
+
+var hpschdJobTimer = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "hpschdJobTimer",
+	Help:    "Historgram for the runtime of mesostic jobs.",
+	Buckets: prometheus.ExponentialBuckets(0.01, 2, 16),
+})
+
+// Live Counts
+var hpschdLiveStale = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "hpschdLiveStale",
+	Help: "Total number of live generations cancelled by a newer edit.",
+})
+
 // Envelope ::: Returns details about the current code execution point.
 // This enables tracing in log events, for instance from within a function:
 //		_, _, fu := Envelope()
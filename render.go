@@ -0,0 +1,210 @@
+/*
+
+	Mesostic Rendering
+
+	Every output format is built from the structured mesostic.Result,
+	so the lines never have to be recovered from padded whitespace.
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maroda/hpschd/mesostic"
+	"github.com/munnerz/goautoneg"
+)
+
+// Response formats for a Mesostic
+const (
+	mesoJSON     = "application/json"
+	mesoText     = "text/plain"
+	mesoHTML     = "text/html"
+	mesoMarkdown = "text/markdown"
+	mesoSVG      = "image/svg+xml"
+)
+
+// mesoFormats ::: Every response format, JSON first as the default.
+var mesoFormats = []string{mesoJSON, mesoText, mesoHTML, mesoMarkdown, mesoSVG}
+
+// formatNames ::: Short names accepted by the 'format' parameter.
+var formatNames = map[string]string{
+	"json":     mesoJSON,
+	"text":     mesoText,
+	"txt":      mesoText,
+	"html":     mesoHTML,
+	"md":       mesoMarkdown,
+	"markdown": mesoMarkdown,
+	"svg":      mesoSVG,
+}
+
+// seedHeader ::: The X-Hpschd-Seed header replays a chance Mesostic in any format, a zero (seed) is no chance.
+func seedHeader(w http.ResponseWriter, seed int64) {
+	if seed != 0 {
+		w.Header().Set("X-Hpschd-Seed", strconv.FormatInt(seed, 10))
+	}
+}
+
+// negotiate ::: Pick the response format from the Accept header, the first offer is the default.
+func negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+	if format := goautoneg.Negotiate(accept, offers); format != "" {
+		return format
+	}
+	return offers[0]
+}
+
+// mesoFormat ::: The response format for a Mesostic.
+// A 'format' query parameter (json, text, html, md, svg, or a media type) wins over the Accept header.
+func mesoFormat(r *http.Request) (string, error) {
+	name := strings.ToLower(r.URL.Query().Get("format"))
+	if name == "" {
+		return negotiate(r, mesoFormats...), nil
+	}
+	if format, ok := formatNames[name]; ok {
+		return format, nil
+	}
+	for _, format := range mesoFormats {
+		if name == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q", name)
+}
+
+// render ::: Write the Mesostic (res) to (w) in the given format.
+func render(w io.Writer, format string, res mesostic.Result) error {
+	switch format {
+	case mesoText:
+		_, err := io.WriteString(w, res.Text)
+		return err
+	case mesoHTML:
+		return renderHTML(w, res)
+	case mesoMarkdown:
+		return renderMarkdown(w, res)
+	case mesoSVG:
+		return renderSVG(w, res)
+	default:
+		return json.NewEncoder(w).Encode(res)
+	}
+}
+
+// contentType ::: The Content-Type header for a response format.
+func contentType(format string) string {
+	if format == mesoJSON {
+		return format
+	}
+	return format + "; charset=utf-8"
+}
+
+// stanzaBreak ::: A blank line follows line (i), it ends a stanza and is not the last line.
+func stanzaBreak(res mesostic.Result, i int) bool {
+	return res.Lines[i].Break && i < len(res.Lines)-1
+}
+
+// renderHTML ::: A standalone page, the SpineString characters are wrapped in styled spans.
+func renderHTML(w io.Writer, res mesostic.Result) error {
+	var b strings.Builder
+
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"UTF-8\" />\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(res.Spine))
+	b.WriteString("<style>\n.mesostic { font-family: monospace; }\n.spine { font-weight: bold; color: midnightblue; }\n</style>\n")
+	b.WriteString("</head>\n<body>\n<pre class=\"mesostic\">\n")
+	for i, lf := range res.Lines {
+		b.WriteString(lf.Padding(res.Pad))
+		b.WriteString(html.EscapeString(lf.West))
+		if lf.Spine != "" {
+			fmt.Fprintf(&b, "<span class=\"spine\">%s</span>", html.EscapeString(lf.Spine))
+		}
+		b.WriteString(html.EscapeString(lf.East))
+		b.WriteString("\n")
+		if stanzaBreak(res, i) {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("</pre>\n</body>\n</html>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// renderMarkdown ::: The SpineString as a heading over the Mesostic in a code block, which keeps the alignment.
+func renderMarkdown(w io.Writer, res mesostic.Result) error {
+	var b strings.Builder
+
+	// the fence is longer than any run of backticks in the Mesostic
+	fence := "```"
+	for strings.Contains(res.Text, fence) {
+		fence += "`"
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n%s\n", strings.ToUpper(res.Spine), fence)
+	for i, lf := range res.Lines {
+		b.WriteString(lf.Padding(res.Pad) + lf.West + lf.Spine + lf.East + "\n")
+		if stanzaBreak(res, i) {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString(fence + "\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SVG layout, in pixels for a 16px monospace font
+const (
+	svgCharWidth  = 10
+	svgLineHeight = 20
+	svgMargin     = 20
+)
+
+// renderSVG ::: Each line is a text element in a monospace font, the SpineString characters in bold.
+func renderSVG(w io.Writer, res mesostic.Result) error {
+	var b strings.Builder
+
+	// widest line in characters
+	cols := 0
+	for _, lf := range res.Lines {
+		cols = max(cols, res.Pad-lf.WChars+utf8.RuneCountInString(lf.West+lf.Spine+lf.East))
+	}
+	rows := len(res.Lines)
+	for i := range res.Lines {
+		if stanzaBreak(res, i) {
+			rows++
+		}
+	}
+	width := cols*svgCharWidth + 2*svgMargin
+	height := rows*svgLineHeight + 2*svgMargin
+
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(res.Spine))
+	b.WriteString("<rect width=\"100%\" height=\"100%\" fill=\"powderblue\"/>\n")
+	b.WriteString("<g font-family=\"monospace\" font-size=\"16\" xml:space=\"preserve\">\n")
+	row := 0
+	for i, lf := range res.Lines {
+		row++
+		if !lf.Miss {
+			fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\">%s%s<tspan font-weight=\"bold\">%s</tspan>%s</text>\n",
+				svgMargin, svgMargin+row*svgLineHeight,
+				lf.Padding(res.Pad), html.EscapeString(lf.West), html.EscapeString(lf.Spine), html.EscapeString(lf.East))
+		}
+		if stanzaBreak(res, i) {
+			row++ // a blank row between stanzas
+		}
+	}
+	b.WriteString("</g>\n</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
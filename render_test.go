@@ -0,0 +1,122 @@
+/*
+
+	Mesostic Rendering Tests
+
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maroda/hpschd/mesostic"
+)
+
+// TestTmesoFormat ::: The 'format' parameter wins over the Accept header, unknown formats fail.
+func TestTmesoFormat(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target mesoFormat() :::\n")
+
+	tests := []struct {
+		query  string
+		accept string
+		want   string
+	}{
+		{"", "", mesoJSON},
+		{"", "text/plain", mesoText},
+		{"", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8", mesoHTML},
+		{"", "image/svg+xml", mesoSVG},
+		{"", "application/pdf", mesoJSON},
+		{"?format=md", "text/html", mesoMarkdown},
+		{"?format=SVG", "", mesoSVG},
+		{"?format=text/plain", "application/json", mesoText},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/app"+tt.query, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		got, err := mesoFormat(req)
+		if err != nil {
+			t.Errorf("%q %q: %v", tt.query, tt.accept, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q %q: format %q, want %q", tt.query, tt.accept, got, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/app?format=pdf", nil)
+	if _, err := mesoFormat(req); err == nil {
+		t.Error("format 'pdf' should fail")
+	}
+}
+
+// TestTrender ::: Every format carries the Mesostic with the SpineString marked.
+func TestTrender(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target render() :::\n")
+
+	source := "the quick brown\nfox jumps over\nthe <lazy> dog\n"
+	res, err := mesostic.Generate(context.Background(), strings.NewReader(source), "cra", mesostic.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{mesoText, []string{"      the quiCk b\nfox jumps oveR\n"}},
+		{mesoJSON, []string{`"spinestring":"cra"`, `"missed":1`}},
+		{mesoHTML, []string{"<title>cra</title>", "      the qui<span class=\"spine\">C</span>k b\n", "&lt;l<span class=\"spine\">A</span>zy&gt;"}},
+		{mesoMarkdown, []string{"# CRA\n\n```\n      the quiCk b\n", "\n```\n"}},
+		{mesoSVG, []string{"<svg xmlns=\"http://www.w3.org/2000/svg\"", "<tspan font-weight=\"bold\">R</tspan>", "&lt;l<tspan"}},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := render(&b, tt.format, res); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s is missing %q:\n%s", tt.format, want, b.String())
+			}
+		}
+	}
+}
+
+// TestTrenderStanzas ::: Every format leaves a blank line between stanzas, none after the last.
+func TestTrenderStanzas(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target render() stanzas :::\n")
+
+	source := "a cat\nthe bat\nno match\nthe cab\n"
+	res, err := mesostic.Generate(context.Background(), strings.NewReader(source), "ab", mesostic.Options{Stanzas: true, MaxCycles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{mesoText, "  the B\n\n  no mAtch\n"},
+		{mesoHTML, "  the <span class=\"spine\">B</span>\n\n  no m<span class=\"spine\">A</span>tch\n"},
+		{mesoMarkdown, "  the B\n\n  no mAtch\nthe caB\n```\n"},
+		{mesoSVG, "<text x=\"20\" y=\"100\">  no m"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := render(&b, tt.format, res); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), tt.want) {
+			t.Errorf("%s is missing %q:\n%s", tt.format, tt.want, b.String())
+		}
+		if strings.Contains(b.String(), "caB\n\n") {
+			t.Errorf("%s breaks after the last stanza:\n%s", tt.format, b.String())
+		}
+	}
+}
@@ -0,0 +1,241 @@
+/*
+
+	Submission Validation
+
+	A submission is checked completely before any response is written,
+	so a bad request gets a 4xx status and a JSON error body instead of a half-written Mesostic.
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maroda/hpschd/mesostic"
+	"github.com/rs/zerolog/log"
+)
+
+// Submission limits
+const (
+	maxBody  = 16 << 20 // Most bytes in a submission body, with the source text
+	maxSpine = 64       // Most characters in a SpineString
+)
+
+// apiError ::: A request that cannot be answered with a Mesostic, written as the JSON error body.
+type apiError struct {
+	Status int    `json:"status"`          // The HTTP status code
+	Field  string `json:"field,omitempty"` // The submission field at fault, if there is one
+	Msg    string `json:"error"`           // What is wrong with it
+}
+
+// Error ::: The message, with the field at fault.
+func (e *apiError) Error() string {
+	if e.Field == "" {
+		return e.Msg
+	}
+	return e.Field + ": " + e.Msg
+}
+
+// badRequest ::: An apiError for the submission field (field).
+func badRequest(field, format string, args ...any) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Field: field, Msg: fmt.Sprintf(format, args...)}
+}
+
+// writeError ::: Write (err) as a JSON error body, with the status of an apiError or 400.
+func writeError(w http.ResponseWriter, err error) {
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		ae = &apiError{Status: http.StatusBadRequest, Msg: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", mesoJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(ae.Status)
+	json.NewEncoder(w).Encode(ae)
+
+	log.Warn().Int("status", ae.Status).Str("field", ae.Field).Msg(ae.Msg)
+}
+
+// decodeSubmit ::: Read a JSON Submit from the request body, at most maxBody bytes.
+func decodeSubmit(w http.ResponseWriter, r *http.Request) (Submit, error) {
+	var subd Submit
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	if err := dec.Decode(&subd); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			return subd, &apiError{Status: http.StatusRequestEntityTooLarge, Msg: fmt.Sprintf("body is over %d bytes", tooBig.Limit)}
+		}
+		return subd, badRequest("", "body is not a JSON submission: %s", err)
+	}
+	return subd, nil
+}
+
+// formSubmit ::: Read a Submit from a form, urlencoded or multipart, at most maxBody bytes.
+// The source is the 'text' field or a .txt file uploaded as 'file', and the SpineString is (spine).
+// The options are fields named as in the JSON submission, e.g. 'algorithm' or 'stanzas', in the body or the query.
+func formSubmit(w http.ResponseWriter, r *http.Request, spine string) (Submit, error) {
+	subd := Submit{SpineString: spine}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = r.ParseMultipartForm(maxBody)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			return subd, &apiError{Status: http.StatusRequestEntityTooLarge, Msg: fmt.Sprintf("body is over %d bytes", tooBig.Limit)}
+		}
+		return subd, badRequest("", "body is not a form: %s", err)
+	}
+
+	subd.Text = r.Form.Get("text")
+	if file, hdr, err := r.FormFile("file"); err == nil {
+		defer file.Close()
+		if subd.Text != "" {
+			return subd, badRequest("file", "send the text or a file, not both")
+		}
+		if !strings.EqualFold(filepath.Ext(hdr.Filename), ".txt") && !strings.HasPrefix(hdr.Header.Get("Content-Type"), mesoText) {
+			return subd, &apiError{Status: http.StatusUnsupportedMediaType, Field: "file", Msg: fmt.Sprintf("%q is not a .txt file", hdr.Filename)}
+		}
+		text, err := io.ReadAll(file)
+		if err != nil {
+			return subd, badRequest("file", "%s", err)
+		}
+		if !utf8.Valid(text) {
+			return subd, &apiError{Status: http.StatusUnsupportedMediaType, Field: "file", Msg: fmt.Sprintf("%q is not UTF-8 text", hdr.Filename)}
+		}
+		subd.Text = string(text)
+	}
+
+	f := formFields{form: r.Form}
+	subd.Algorithm = r.Form.Get("algorithm")
+	subd.Procedure = r.Form.Get("procedure")
+	subd.Fold = f.bool("fold")
+	subd.MissLimit = f.int("misslimit")
+	subd.MissFraction = f.float("missfraction")
+	subd.WestWidth = f.int("westwidth")
+	subd.EastWidth = f.int("eastwidth")
+	subd.Wings = r.Form.Get("wings")
+	subd.Bounded = f.bool("bounded")
+	subd.Adjacent = f.bool("adjacent")
+	subd.Stanzas = f.bool("stanzas")
+	subd.Cycles = f.int("cycles")
+	subd.Segment = r.Form.Get("segment")
+	subd.SegmentWidth = f.int("segmentwidth")
+	subd.Unwrap = f.bool("unwrap")
+	subd.Dehyphenate = f.bool("dehyphenate")
+	subd.Normalize = f.bool("normalize")
+	subd.Chance = f.bool("chance")
+	subd.Seed = f.int64("seed")
+
+	return subd, f.err
+}
+
+// formFields ::: Typed form values, keeping the first one that cannot be read.
+type formFields struct {
+	form url.Values
+	err  error
+}
+
+// value ::: Parse the form value (name) as a (kind) with (parse), an empty value is the zero value.
+func (f *formFields) value(name, kind string, parse func(string) error) {
+	v := strings.TrimSpace(f.form.Get(name))
+	if v == "" || f.err != nil {
+		return
+	}
+	if err := parse(v); err != nil {
+		f.err = badRequest(name, "%q is not %s", v, kind)
+	}
+}
+
+// bool ::: A checkbox or true/false form value, checkboxes send "on".
+func (f *formFields) bool(name string) bool {
+	var b bool
+	f.value(name, "true or false", func(v string) (err error) {
+		if v == "on" {
+			b = true
+			return nil
+		}
+		b, err = strconv.ParseBool(v)
+		return err
+	})
+	return b
+}
+
+// int ::: A whole number form value.
+func (f *formFields) int(name string) int {
+	var n int
+	f.value(name, "a whole number", func(v string) (err error) {
+		n, err = strconv.Atoi(v)
+		return err
+	})
+	return n
+}
+
+// int64 ::: A whole number form value, for seeds.
+func (f *formFields) int64(name string) int64 {
+	var n int64
+	f.value(name, "a whole number", func(v string) (err error) {
+		n, err = strconv.ParseInt(v, 10, 64)
+		return err
+	})
+	return n
+}
+
+// float ::: A decimal number form value.
+func (f *formFields) float(name string) float64 {
+	var x float64
+	f.value(name, "a number", func(v string) (err error) {
+		x, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	return x
+}
+
+// validate ::: Check the submission and its options (q) completely, returning the Mesostic options.
+//
+//	SpineString ::: 1 to maxSpine characters, all of them letters
+//	Text ::: not empty or only whitespace
+//	options ::: known names and values the engine accepts
+func (subd Submit) validate(q url.Values) (mesostic.Options, error) {
+	spine := subd.SpineString
+	switch n := len(mesostic.Spine(spine)); {
+	case n == 0:
+		return mesostic.Options{}, badRequest("spinestring", "empty")
+	case n > maxSpine:
+		return mesostic.Options{}, badRequest("spinestring", "%d characters, the most is %d", n, maxSpine)
+	}
+	for _, r := range spine {
+		if !unicode.IsLetter(r) && !unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me) {
+			return mesostic.Options{}, badRequest("spinestring", "%q is not a letter", r)
+		}
+	}
+
+	if strings.TrimSpace(subd.Text) == "" {
+		return mesostic.Options{}, badRequest("text", "empty")
+	}
+
+	opts, err := subd.Options(q)
+	if err != nil {
+		return opts, badRequest("options", "%s", err)
+	}
+	if _, err := mesostic.NewBuilder(spine, opts); err != nil {
+		return opts, badRequest("options", "%s", err)
+	}
+	return opts, nil
+}
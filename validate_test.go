@@ -0,0 +1,129 @@
+/*
+
+	Submission Validation Tests
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestTvalidate ::: Every field is checked before a Mesostic is built.
+func TestTvalidate(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target Submit.validate() :::\n")
+
+	tests := []struct {
+		subd  Submit
+		query string
+		field string // empty when the submission is valid
+	}{
+		{Submit{Text: "the quick brown fox", SpineString: "fox"}, "", ""},
+		{Submit{Text: "the quick brown fox", SpineString: "Müller"}, "", ""},
+		{Submit{Text: "the quick brown fox", SpineString: "été"}, "", ""}, // combining marks belong to their letters
+		{Submit{Text: "the quick brown fox", SpineString: ""}, "", "spinestring"},
+		{Submit{Text: "the quick brown fox", SpineString: strings.Repeat("a", maxSpine+1)}, "", "spinestring"},
+		{Submit{Text: "the quick brown fox", SpineString: "john cage"}, "", "spinestring"},
+		{Submit{Text: "the quick brown fox", SpineString: "cage4"}, "", "spinestring"},
+		{Submit{Text: "", SpineString: "fox"}, "", "text"},
+		{Submit{Text: " \n\t\n", SpineString: "fox"}, "", "text"},
+		{Submit{Text: "the quick brown fox", SpineString: "fox", Algorithm: "75"}, "", "options"},
+		{Submit{Text: "the quick brown fox", SpineString: "fox"}, "segment=chapters", "options"},
+		{Submit{Text: "the quick brown fox", SpineString: "fox", WestWidth: -1}, "", "options"},
+		{Submit{Text: "the quick brown fox", SpineString: "fox", MissFraction: 2}, "", "options"},
+		{Submit{Text: "the quick brown fox", SpineString: "fox", Procedure: "diastic", Chance: true}, "", "options"},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		_, err := tt.subd.validate(q)
+
+		var ae *apiError
+		switch {
+		case tt.field == "" && err != nil:
+			t.Errorf("%+v: %v", tt.subd, err)
+		case tt.field != "" && !errors.As(err, &ae):
+			t.Errorf("%+v: %v, want an apiError", tt.subd, err)
+		case tt.field != "" && (ae.Field != tt.field || ae.Status != http.StatusBadRequest):
+			t.Errorf("%+v: %+v, want field %s", tt.subd, ae, tt.field)
+		}
+	}
+}
+
+// TestTJSubmitErrors ::: Bad submissions get a 4xx status and a JSON error body, and the server stays up.
+func TestTJSubmitErrors(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target JSubmit() errors :::\n")
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+		field  string
+	}{
+		{"/app", `{"text": "the quick brown fox", "spinestring": "fox"`, http.StatusBadRequest, ""},
+		{"/app", `not json`, http.StatusBadRequest, ""},
+		{"/app", `{"text": "the quick brown fox", "spinestring": ""}`, http.StatusBadRequest, "spinestring"},
+		{"/app", `{"text": "the quick brown fox"}`, http.StatusBadRequest, "spinestring"},
+		{"/app", `{"text": "", "spinestring": "fox"}`, http.StatusBadRequest, "text"},
+		{"/app", `{"text": "the quick brown fox", "spinestring": "f-x"}`, http.StatusBadRequest, "spinestring"},
+		{"/app?format=pdf", `{"text": "the quick brown fox", "spinestring": "fox"}`, http.StatusNotAcceptable, "format"},
+		{"/app", `{"text": "` + strings.Repeat("a", maxBody) + `", "spinestring": "fox"}`, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		JSubmit(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+
+		if rec.Code != tt.status {
+			t.Errorf("%.60s: status %d, want %d", tt.body, rec.Code, tt.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != mesoJSON {
+			t.Errorf("%.60s: Content-Type %q", tt.body, ct)
+		}
+		var ae apiError
+		if err := json.NewDecoder(rec.Body).Decode(&ae); err != nil {
+			t.Fatalf("%.60s: %v", tt.body, err)
+		}
+		if ae.Status != tt.status || ae.Field != tt.field || ae.Msg == "" {
+			t.Errorf("%.60s: error body %+v", tt.body, ae)
+		}
+	}
+}
+
+// TestTformSubmit ::: Form fields are read into a Submit, with checkboxes and numbers.
+func TestTformSubmit(t *testing.T) {
+	fmt.Printf("\n\t::: Test Target formSubmit() :::\n")
+
+	form := url.Values{
+		"text":      {"the quick brown fox"},
+		"algorithm": {"100"},
+		"stanzas":   {"on"},
+		"fold":      {"true"},
+		"westwidth": {"12"},
+		"seed":      {"-9007199254740993"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/app/fox?segment=clauses", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	subd, err := formSubmit(httptest.NewRecorder(), req, "fox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Submit{Text: "the quick brown fox", SpineString: "fox", Algorithm: "100", Segment: "clauses", Stanzas: true, Fold: true, WestWidth: 12, Seed: -9007199254740993}
+	if subd != want {
+		t.Errorf("%+v\nwant:\n%+v", subd, want)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/app/fox", strings.NewReader("text=fox&cycles=two"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var ae *apiError
+	if _, err := formSubmit(httptest.NewRecorder(), req, "fox"); !errors.As(err, &ae) || ae.Field != "cycles" {
+		t.Errorf("cycles=two: %v", err)
+	}
+}